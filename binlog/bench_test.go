@@ -0,0 +1,147 @@
+//
+// bench_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"testing"
+)
+
+// appendEvent appends an event of MySQL 5.7 with its common header and
+// checksum to the binlog in buf
+func appendEvent(buf *bytes.Buffer, t LogEventType, body []byte) {
+	size := BINLOG_EVENT_HEADER_LEN + len(body) + BINLOG_CHECKSUM_LEN
+	event := make([]byte, BINLOG_EVENT_HEADER_LEN, size)
+	binary.LittleEndian.PutUint32(event, 1570000000)
+	event[4] = byte(t)
+	binary.LittleEndian.PutUint32(event[5:], 1)
+	binary.LittleEndian.PutUint32(event[9:], uint32(size))
+	binary.LittleEndian.PutUint32(event[13:], uint32(buf.Len()+size))
+	event = append(event, body...)
+	event = append(event, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(event[size-BINLOG_CHECKSUM_LEN:], crc32.ChecksumIEEE(event[:size-BINLOG_CHECKSUM_LEN]))
+	buf.Write(event)
+}
+
+// genBinlog generates a binlog of txns transactions, each of them inserts
+// rows rows of `test`.`bench` (id INT, name VARCHAR(64), val BIGINT) in row
+// format, so that the benchmarks are not dominated by the fixed overhead
+func genBinlog(tb testing.TB, txns, rows int) []byte {
+	var buf bytes.Buffer
+	buf.Write(unhex(tb, fde57))
+
+	// thread_id, exec_time, schema len, error_code, status_vars len
+	begin := []byte{5, 0, 0, 0, 0, 0, 0, 0, 4, 0, 0, 0, 0}
+	begin = append(begin, "test\x00BEGIN"...)
+	tableMap := []byte{80, 0, 0, 0, 0, 0, 1, 0, 4, 't', 'e', 's', 't', 0, 5, 'b', 'e', 'n', 'c', 'h', 0,
+		3, byte(MYSQL_TYPE_LONG), byte(MYSQL_TYPE_VARCHAR), byte(MYSQL_TYPE_LONGLONG), 2, 64, 0, 0x06}
+
+	for i := 0; i < txns; i++ {
+		appendEvent(&buf, QUERY_EVENT, begin)
+		appendEvent(&buf, TABLE_MAP_EVENT, tableMap)
+
+		// table_id, flags, extra_data len, column count, columns present
+		body := []byte{80, 0, 0, 0, 0, 0, 1, 0, 2, 0, 3, 0x07}
+		for j := 0; j < rows; j++ {
+			id := i*rows + j
+			name := fmt.Sprintf("name-%d", id)
+			body = append(body, 0)
+			body = append(body, byte(id), byte(id>>8), byte(id>>16), byte(id>>24))
+			body = append(body, byte(len(name)))
+			body = append(body, name...)
+			body = append(body, make([]byte, 8)...)
+			binary.LittleEndian.PutUint64(body[len(body)-8:], uint64(id)*1000003)
+		}
+
+		appendEvent(&buf, WRITE_ROWS_EVENT, body)
+		xid := make([]byte, 8)
+		binary.LittleEndian.PutUint64(xid, uint64(i))
+		appendEvent(&buf, XID_EVENT, xid)
+	}
+
+	return buf.Bytes()
+}
+
+func TestGenBinlog(t *testing.T) {
+	events := parseEvents(t, genBinlog(t, 2, 3))
+	if len(events) != 9 {
+		t.Fatalf("got %d events, want 9", len(events))
+	}
+
+	rows := events[7].(*RowsEvent)
+	if rows.Err() != nil {
+		t.Fatal(rows.Err())
+	}
+
+	want := []Any{int32(5), "name-5", int64(5000015)}
+	if got := rows.Rows()[2].Values; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func BenchmarkReadEvent(b *testing.B) {
+	data := genBinlog(b, 1000, 50)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser, err := NewParser(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for {
+			if _, err = parser.ReadEvent(); err != nil {
+				break
+			}
+		}
+
+		if err != io.EOF {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSkipEvent(b *testing.B) {
+	data := genBinlog(b, 1000, 50)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parser, err := NewParser(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for {
+			if err = parser.SkipEvent(); err != nil {
+				break
+			}
+		}
+
+		if err != io.EOF {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRowDecode(b *testing.B) {
+	events := parseEvents(b, genBinlog(b, 1, 500))
+	tableMap, rows := events[2].(*TableMapEvent), events[3].(*RowsEvent)
+	b.SetBytes(int64(len(rows.rowsData)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows.decodeRows(tableMap)
+		if rows.err != nil {
+			b.Fatal(rows.err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+//
+// binlogformat.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Detect the binlog_format a binlog is written with
+//
+
+package binlog
+
+import (
+	"io"
+)
+
+// BinlogFormat is the binlog_format told from the DML in a binlog, MIXED is
+// both the statement and the row based DML
+type BinlogFormat uint8
+
+const (
+	BINLOG_FORMAT_UNKNOWN   BinlogFormat = 0 // no DML
+	BINLOG_FORMAT_STATEMENT BinlogFormat = 1
+	BINLOG_FORMAT_ROW       BinlogFormat = 2
+	BINLOG_FORMAT_MIXED     BinlogFormat = BINLOG_FORMAT_STATEMENT | BINLOG_FORMAT_ROW
+)
+
+func (self BinlogFormat) String() string {
+	switch self {
+	case BINLOG_FORMAT_STATEMENT:
+		return "STATEMENT"
+	case BINLOG_FORMAT_ROW:
+		return "ROW"
+	case BINLOG_FORMAT_MIXED:
+		return "MIXED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Add adds the format of an event, a DML query event is statement based and a
+// rows event is row based, the DDL and the other events are neither
+func (self *BinlogFormat) Add(event BinLogEvent) {
+	switch e := event.(type) {
+	case *QueryEvent:
+		if e.IsDML() {
+			*self |= BINLOG_FORMAT_STATEMENT
+		}
+	case *RowsEvent:
+		*self |= BINLOG_FORMAT_ROW
+	}
+}
+
+// DetectFormat reads the rest of the binlog and returns its format. Only the
+// query events are decoded, the rows events are skipped by their headers.
+func (self *Parser) DetectFormat() (BinlogFormat, error) {
+	if self.fde == nil {
+		self.fde = new(FormatDescriptionEvent)
+	}
+
+	format := BINLOG_FORMAT_UNKNOWN
+	for {
+		header, err := self.readEventHeader()
+		if err == io.EOF {
+			return format, nil
+		} else if err != nil {
+			return format, err
+		}
+
+		if isRowsEvent(header.EventType) {
+			format |= BINLOG_FORMAT_ROW
+			if err = self.skipBody(header); err != nil {
+				return format, err
+			}
+
+			continue
+		}
+
+		event, err := self.readEvent(header)
+		if event, err = self.countEvent(header, event, err); err != nil {
+			return format, err
+		}
+
+		format.Add(event)
+	}
+}
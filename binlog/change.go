@@ -0,0 +1,115 @@
+//
+// change.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Debezium style change records of row events
+//
+
+package binlog
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+)
+
+type ChangeSource struct {
+	File     string `json:"file"`
+	Pos      int64  `json:"pos"`
+	Gtid     string `json:"gtid,omitempty"`
+	TsMs     int64  `json:"ts_ms"`
+	ServerId uint32 `json:"server_id"`
+	Db       string `json:"db"`
+	Table    string `json:"table"`
+}
+
+// ChangeRecord is the change envelope of a row, op is c (insert), u (update)
+// or d (delete). The images map the column names to the values, the columns
+// not in the image are left out.
+type ChangeRecord struct {
+	Op      string                 `json:"op"`
+	Before  map[string]interface{} `json:"before"`
+	After   map[string]interface{} `json:"after"`
+	Source  ChangeSource           `json:"source"`
+	Columns []string               `json:"columns"`
+}
+
+func (self *RowsEvent) image(row []Any, columns []byte) map[string]interface{} {
+	image := make(map[string]interface{})
+	for i := 0; i < self.columnCount; i++ {
+		if bitSet(columns, i) {
+			image[self.tableMap.ColumnName(i)] = jsonValue(self.columnValue(row, i))
+		}
+	}
+
+	return image
+}
+
+// jsonValue returns NaN and the infinities of FLOAT and DOUBLE as strings,
+// which json.Marshal fails on
+func jsonValue(val Any) Any {
+	switch v := val.(type) {
+	case float32:
+		if f := float64(v); math.IsNaN(f) || math.IsInf(f, 0) {
+			return strconv.FormatFloat(f, 'g', -1, 32)
+		}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		}
+	}
+
+	return val
+}
+
+// NewChangeRecords converts the rows of a decoded rows event to change
+// records. file and pos are where the event is in the binlogs, gtid is the
+// GTID of the transaction, which is empty if GTID is not enabled.
+func NewChangeRecords(event *RowsEvent, file string, pos int64, gtid string) ([]*ChangeRecord, error) {
+	if event.err != nil {
+		return nil, event.err
+	}
+
+	if event.tableMap == nil {
+		return nil, errors.New("RowsEvent is not decoded")
+	}
+
+	source := ChangeSource{
+		File:     file,
+		Pos:      pos,
+		Gtid:     gtid,
+		TsMs:     int64(event.header.Timestamp) * 1000,
+		ServerId: event.header.ServerId,
+		Db:       event.tableMap.Schema,
+		Table:    event.tableMap.Table,
+	}
+
+	columns := make([]string, event.columnCount)
+	for i := range columns {
+		columns[i] = event.tableMap.ColumnName(i)
+	}
+
+	var records []*ChangeRecord
+	switch event.header.EventType {
+	case WRITE_ROWS_EVENT_V1, WRITE_ROWS_EVENT:
+		for _, row := range event.rows {
+			records = append(records, &ChangeRecord{"c", nil, event.image(row, event.columns), source, columns})
+		}
+	case DELETE_ROWS_EVENT_V1, DELETE_ROWS_EVENT:
+		for _, row := range event.rows {
+			records = append(records, &ChangeRecord{"d", event.image(row, event.columns), nil, source, columns})
+		}
+	default:
+		for i := 0; i+1 < len(event.rows); i += 2 {
+			records = append(records, &ChangeRecord{"u", event.image(event.rows[i], event.columns),
+				event.image(event.rows[i+1], event.columnsAfter), source, columns})
+		}
+	}
+
+	return records, nil
+}
+
+func MarshalChangeRecord(record *ChangeRecord) ([]byte, error) {
+	return json.Marshal(record)
+}
@@ -0,0 +1,116 @@
+//
+// charset.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Transcoding of the string values to UTF-8 by the column charsets in the
+// optional metadata of TABLE_MAP_EVENT
+//
+
+package binlog
+
+import (
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+)
+
+const BINARY_COLLATION = 63
+
+// the encodings of the charsets by the collation ids, see
+// INFORMATION_SCHEMA.COLLATIONS, nil for utf8 and utf8mb4
+var collationEncodings = make(map[uint64]encoding.Encoding)
+
+func init() {
+	add := func(enc encoding.Encoding, ids ...uint64) {
+		for _, id := range ids {
+			collationEncodings[id] = enc
+		}
+	}
+
+	addRange := func(enc encoding.Encoding, from, to uint64) {
+		for id := from; id <= to; id++ {
+			collationEncodings[id] = enc
+		}
+	}
+
+	// utf8mb3 and utf8mb4
+	add(nil, 33, 76, 83, 45, 46)
+	addRange(nil, 192, 215)
+	addRange(nil, 223, 247)
+	addRange(nil, 255, 323)
+
+	// MySQL latin1 is cp1252
+	add(charmap.Windows1252, 5, 8, 15, 31, 47, 48, 49, 94)
+	add(charmap.ISO8859_2, 9, 21, 27, 77)
+	add(charmap.ISO8859_7, 25, 70)
+	add(charmap.ISO8859_8, 16, 71)
+	add(charmap.ISO8859_9, 30, 78)
+	add(charmap.ISO8859_13, 20, 41, 42, 79)
+	add(charmap.Windows1250, 26, 34, 44, 66, 99)
+	add(charmap.Windows1251, 14, 23, 50, 51, 52)
+	add(charmap.Windows1256, 57, 67)
+	add(charmap.Windows1257, 29, 58, 59)
+	add(charmap.KOI8R, 7, 74)
+	add(charmap.KOI8U, 22, 75)
+	add(charmap.CodePage850, 4, 80)
+	add(charmap.CodePage866, 36, 68)
+	add(charmap.CodePage852, 40, 81)
+
+	// gb2312 is a subset of gbk
+	add(simplifiedchinese.GBK, 24, 86, 28, 87)
+	addRange(simplifiedchinese.GB18030, 248, 250)
+	add(traditionalchinese.Big5, 1, 84)
+	add(japanese.ShiftJIS, 13, 88, 95, 96)
+	add(japanese.EUCJP, 12, 91, 97, 98)
+	add(korean.EUCKR, 19, 85)
+
+	ucs2 := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	add(ucs2, 35, 90, 159)
+	addRange(ucs2, 128, 151)
+	add(ucs2, 54, 55)
+	addRange(ucs2, 101, 124)
+	add(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), 56, 62)
+	add(utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM), 60, 61)
+	addRange(utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM), 160, 183)
+}
+
+// transcode converts the value of the i-th column to an UTF-8 string by the
+// charset of the column in the metadata. The value is kept as is if the
+// charset is unknown or binary, or the column is not a character column.
+func (self *TableMapEvent) transcode(i int, val Any) Any {
+	if self.Metadata == nil || self.Metadata.ColumnCharsets == nil || !isCharacterType(self.realType(i)) {
+		return val
+	}
+
+	collation := self.Metadata.ColumnCharsets[i]
+	enc, ok := collationEncodings[collation]
+	if !ok || collation == BINARY_COLLATION {
+		return val
+	}
+
+	var raw []byte
+	switch v := val.(type) {
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return val
+	}
+
+	if enc == nil {
+		return string(raw)
+	}
+
+	text, err := enc.NewDecoder().Bytes(raw)
+	if err != nil {
+		return val
+	}
+
+	return string(text)
+}
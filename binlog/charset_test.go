@@ -0,0 +1,58 @@
+//
+// charset_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"reflect"
+	"testing"
+)
+
+// the table maps of `test`.`cs` and `test`.`cs2` (a, b, c, d VARCHAR(10),
+// e INT), the charsets of the VARCHAR columns are latin1, gbk, binary and
+// utf8mb4 in the DEFAULT_CHARSET metadata of cs, and latin1, gbk, binary and
+// the unknown collation 999 in the COLUMN_CHARSET metadata of cs2. The rows
+// are 'caf\xe9', '\xd6\xd0', '\xe9\x00', 'é' and 'caf\xe9', 1.
+const rowsCharsets = `
+	# TABLE_MAP_EVENT at 123
+	80 4c 94 5d 13 01 00 00 00 44 00 00 00 bf 00 00 00 00 00 54 00 00 00 00
+	00 01 00 04 74 65 73 74 00 02 63 73 00 05 0f 0f 0f 0f 03 08 0a 00 0a 00
+	0a 00 0a 00 1f 02 09 08 01 1c 02 3f 03 fc ff 00 c3 7b b4 39
+	# TABLE_MAP_EVENT at 191
+	80 4c 94 5d 13 01 00 00 00 42 00 00 00 01 01 00 00 00 00 55 00 00 00 00
+	00 01 00 04 74 65 73 74 00 03 63 73 32 00 05 0f 0f 0f 0f 03 08 0a 00 0a
+	00 0a 00 0a 00 1f 03 06 08 1c 3f fc e7 03 f7 02 df 3f
+	# WRITE_ROWS_EVENT at 257
+	80 4c 94 5d 1e 01 00 00 00 36 00 00 00 37 01 00 00 00 00 54 00 00 00 00
+	00 01 00 02 00 05 1f 00 04 63 61 66 e9 02 d6 d0 02 e9 00 02 c3 a9 01 00
+	00 00 93 35 21 67
+	# WRITE_ROWS_EVENT at 311
+	80 4c 94 5d 1e 01 00 00 00 38 00 00 00 6f 01 00 00 00 00 55 00 00 00 00
+	00 01 00 02 00 05 1f 00 04 63 61 66 e9 02 d6 d0 02 e9 00 04 63 61 66 e9
+	01 00 00 00 91 83 7a 2d
+`
+
+func TestColumnCharsets(t *testing.T) {
+	tests := []struct {
+		charsets []uint64
+		values   []Any
+	}{
+		{[]uint64{8, 28, 63, 255, 0}, []Any{"café", "中", "\xe9\x00", "é", int64(1)}},
+		// the value of an unknown charset is kept as is
+		{[]uint64{8, 28, 63, 999, 0}, []Any{"café", "中", "\xe9\x00", "caf\xe9", int64(1)}},
+	}
+
+	events := readEvents(t, fde80+rowsCharsets)
+	for i, test := range tests {
+		tableMap, rows := events[1+i].(*TableMapEvent), events[3+i].(*RowsEvent)
+		if got := tableMap.Metadata.ColumnCharsets; !reflect.DeepEqual(got, test.charsets) {
+			t.Errorf("%s: got charsets %v, want %v", tableMap.Table, got, test.charsets)
+		}
+
+		if got := rows.Rows()[0].Values; !reflect.DeepEqual(got, test.values) {
+			t.Errorf("%s: got %#v, want %#v", tableMap.Table, got, test.values)
+		}
+	}
+}
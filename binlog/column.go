@@ -0,0 +1,784 @@
+//
+// column.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Column types and the decoding of column values in row events
+//
+
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// column type, from include/mysql_com.h (enum_field_types)
+type ColumnType uint8
+
+const (
+	MYSQL_TYPE_DECIMAL     ColumnType = 0
+	MYSQL_TYPE_TINY        ColumnType = 1
+	MYSQL_TYPE_SHORT       ColumnType = 2
+	MYSQL_TYPE_LONG        ColumnType = 3
+	MYSQL_TYPE_FLOAT       ColumnType = 4
+	MYSQL_TYPE_DOUBLE      ColumnType = 5
+	MYSQL_TYPE_NULL        ColumnType = 6
+	MYSQL_TYPE_TIMESTAMP   ColumnType = 7
+	MYSQL_TYPE_LONGLONG    ColumnType = 8
+	MYSQL_TYPE_INT24       ColumnType = 9
+	MYSQL_TYPE_DATE        ColumnType = 10
+	MYSQL_TYPE_TIME        ColumnType = 11
+	MYSQL_TYPE_DATETIME    ColumnType = 12
+	MYSQL_TYPE_YEAR        ColumnType = 13
+	MYSQL_TYPE_NEWDATE     ColumnType = 14
+	MYSQL_TYPE_VARCHAR     ColumnType = 15
+	MYSQL_TYPE_BIT         ColumnType = 16
+	MYSQL_TYPE_TIMESTAMP2  ColumnType = 17
+	MYSQL_TYPE_DATETIME2   ColumnType = 18
+	MYSQL_TYPE_TIME2       ColumnType = 19
+	MYSQL_TYPE_TYPED_ARRAY ColumnType = 20
+	MYSQL_TYPE_JSON        ColumnType = 245
+	MYSQL_TYPE_NEWDECIMAL  ColumnType = 246
+	MYSQL_TYPE_ENUM        ColumnType = 247
+	MYSQL_TYPE_SET         ColumnType = 248
+	MYSQL_TYPE_TINY_BLOB   ColumnType = 249
+	MYSQL_TYPE_MEDIUM_BLOB ColumnType = 250
+	MYSQL_TYPE_LONG_BLOB   ColumnType = 251
+	MYSQL_TYPE_BLOB        ColumnType = 252
+	MYSQL_TYPE_VAR_STRING  ColumnType = 253
+	MYSQL_TYPE_STRING      ColumnType = 254
+	MYSQL_TYPE_GEOMETRY    ColumnType = 255
+)
+
+func (self ColumnType) String() string {
+	switch self {
+	case MYSQL_TYPE_DECIMAL:
+		return "DECIMAL"
+	case MYSQL_TYPE_TINY:
+		return "TINY"
+	case MYSQL_TYPE_SHORT:
+		return "SHORT"
+	case MYSQL_TYPE_LONG:
+		return "LONG"
+	case MYSQL_TYPE_FLOAT:
+		return "FLOAT"
+	case MYSQL_TYPE_DOUBLE:
+		return "DOUBLE"
+	case MYSQL_TYPE_NULL:
+		return "NULL"
+	case MYSQL_TYPE_TIMESTAMP:
+		return "TIMESTAMP"
+	case MYSQL_TYPE_LONGLONG:
+		return "LONGLONG"
+	case MYSQL_TYPE_INT24:
+		return "INT24"
+	case MYSQL_TYPE_DATE:
+		return "DATE"
+	case MYSQL_TYPE_TIME:
+		return "TIME"
+	case MYSQL_TYPE_DATETIME:
+		return "DATETIME"
+	case MYSQL_TYPE_YEAR:
+		return "YEAR"
+	case MYSQL_TYPE_NEWDATE:
+		return "NEWDATE"
+	case MYSQL_TYPE_VARCHAR:
+		return "VARCHAR"
+	case MYSQL_TYPE_BIT:
+		return "BIT"
+	case MYSQL_TYPE_TIMESTAMP2:
+		return "TIMESTAMP2"
+	case MYSQL_TYPE_DATETIME2:
+		return "DATETIME2"
+	case MYSQL_TYPE_TIME2:
+		return "TIME2"
+	case MYSQL_TYPE_TYPED_ARRAY:
+		return "TYPED_ARRAY"
+	case MYSQL_TYPE_JSON:
+		return "JSON"
+	case MYSQL_TYPE_NEWDECIMAL:
+		return "NEWDECIMAL"
+	case MYSQL_TYPE_ENUM:
+		return "ENUM"
+	case MYSQL_TYPE_SET:
+		return "SET"
+	case MYSQL_TYPE_TINY_BLOB:
+		return "TINY_BLOB"
+	case MYSQL_TYPE_MEDIUM_BLOB:
+		return "MEDIUM_BLOB"
+	case MYSQL_TYPE_LONG_BLOB:
+		return "LONG_BLOB"
+	case MYSQL_TYPE_BLOB:
+		return "BLOB"
+	case MYSQL_TYPE_VAR_STRING:
+		return "VAR_STRING"
+	case MYSQL_TYPE_STRING:
+		return "STRING"
+	case MYSQL_TYPE_GEOMETRY:
+		return "GEOMETRY"
+	default:
+		return "INVALID"
+	}
+}
+
+// readPackedInt reads a length encoded integer
+func readPackedInt(r *bytes.Reader) (uint64, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int
+	switch {
+	case first < 251:
+		return uint64(first), nil
+	case first == 252:
+		size = 2
+	case first == 253:
+		size = 3
+	case first == 254:
+		size = 8
+	default:
+		return 0, fmt.Errorf("Invalid packed integer prefix 0x%x", first)
+	}
+
+	return readUintLE(r, size)
+}
+
+// readColumnMeta reads the metadata of the columns in TABLE_MAP_EVENT, see
+// table_def::table_def in sql/rpl_utility.cc for the layout. The metadata of
+// a TYPED_ARRAY column is the element type followed by the metadata of the
+// element, see Field_typed_array::do_save_field_metadata, and the meta of the
+// column is the element type.
+func readColumnMeta(types []ColumnType, text []byte) ([]uint16, error) {
+	meta := make([]uint16, len(types))
+	pos := 0
+	for i, t := range types {
+		if t == MYSQL_TYPE_TYPED_ARRAY {
+			if pos >= len(text) {
+				return nil, errors.New("Invalid column metadata len")
+			}
+
+			meta[i] = uint16(text[pos])
+			pos += 1 + typedArrayMetaLen(ColumnType(text[pos]))
+			if pos > len(text) {
+				return nil, errors.New("Invalid column metadata len")
+			}
+
+			continue
+		}
+
+		size := columnMetaLen(t)
+		if pos+size > len(text) {
+			return nil, errors.New("Invalid column metadata len")
+		}
+
+		switch t {
+		case MYSQL_TYPE_VARCHAR, MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_BIT:
+			meta[i] = binary.LittleEndian.Uint16(text[pos:])
+		case MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_STRING, MYSQL_TYPE_ENUM, MYSQL_TYPE_SET:
+			meta[i] = binary.BigEndian.Uint16(text[pos:])
+		default:
+			if size == 1 {
+				meta[i] = uint16(text[pos])
+			}
+		}
+
+		pos += size
+	}
+
+	return meta, nil
+}
+
+func columnMetaLen(t ColumnType) int {
+	switch t {
+	case MYSQL_TYPE_FLOAT, MYSQL_TYPE_DOUBLE, MYSQL_TYPE_BLOB, MYSQL_TYPE_TINY_BLOB,
+		MYSQL_TYPE_MEDIUM_BLOB, MYSQL_TYPE_LONG_BLOB, MYSQL_TYPE_GEOMETRY, MYSQL_TYPE_JSON,
+		MYSQL_TYPE_TIMESTAMP2, MYSQL_TYPE_DATETIME2, MYSQL_TYPE_TIME2:
+		return 1
+	case MYSQL_TYPE_VARCHAR, MYSQL_TYPE_BIT, MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_STRING,
+		MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_ENUM, MYSQL_TYPE_SET:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// typedArrayMetaLen returns the len of the metadata of the element of a
+// TYPED_ARRAY column, the temporal types have the fractional seconds
+// precision even if they are not of the 5.6.4 formats
+func typedArrayMetaLen(t ColumnType) int {
+	switch t {
+	case MYSQL_TYPE_VARCHAR, MYSQL_TYPE_NEWDECIMAL:
+		return 2
+	case MYSQL_TYPE_TIME, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP, MYSQL_TYPE_TIME2,
+		MYSQL_TYPE_DATETIME2, MYSQL_TYPE_TIMESTAMP2:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// unpackStringMeta returns the real type and the max length in bytes of a
+// MYSQL_TYPE_STRING column, which is also used for ENUM and SET. The meta is
+// the real type followed by the length, but the bits 4 and 5 of the type are
+// the inverted bits 8 and 9 of the length of a CHAR longer than 255 bytes,
+// see Field_string::do_save_field_metadata.
+func unpackStringMeta(meta uint16) (ColumnType, int) {
+	if meta < 256 {
+		return MYSQL_TYPE_STRING, int(meta)
+	}
+
+	byte0, byte1 := int(meta>>8), int(meta&0xff)
+	if byte0&0x30 != 0x30 {
+		return ColumnType(byte0 | 0x30), byte1 | ((byte0&0x30)^0x30)<<4
+	}
+
+	return ColumnType(byte0), byte1
+}
+
+// readUintBE reads a big endian unsigned integer of size bytes
+func readUintBE(r *bytes.Reader, size int) (uint64, error) {
+	buf := make([]byte, size)
+	if _, err := readFull(r, buf); err != nil {
+		return 0, err
+	}
+
+	var val uint64
+	for _, b := range buf {
+		val = val<<8 | uint64(b)
+	}
+
+	return val, nil
+}
+
+// readUintLE reads a little endian unsigned integer of size bytes
+func readUintLE(r *bytes.Reader, size int) (uint64, error) {
+	buf := make([]byte, 8)
+	if _, err := readFull(r, buf[:size]); err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint64(buf), nil
+}
+
+func readFull(r *bytes.Reader, buf []byte) (int, error) {
+	if r.Len() < len(buf) {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	// an empty value may end the row image, where Read returns io.EOF
+	if len(buf) == 0 {
+		return 0, nil
+	}
+
+	return r.Read(buf)
+}
+
+// decodeValue decodes a non-NULL column value from the row image. The result
+// is an int64 for integers, YEAR and the ENUM index (see toUnsigned for the
+// unsigned ones), an uint64 for BIT and the SET bitmap, a float32 for FLOAT
+// and a float64 for DOUBLE, a string for CHAR,
+// VARCHAR, DECIMAL and the DATE/TIME/DATETIME
+// types, a time.Time for TIMESTAMP, a *Geometry for GEOMETRY, a *TypedArray
+// for TYPED_ARRAY and []byte for BLOB/TEXT and JSON (the binary JSON is not
+// decoded).
+func decodeValue(r *bytes.Reader, t ColumnType, meta uint16) (Any, error) {
+	switch t {
+	case MYSQL_TYPE_TINY:
+		val, err := readUintLE(r, 1)
+		return int64(int8(val)), err
+	case MYSQL_TYPE_SHORT:
+		val, err := readUintLE(r, 2)
+		return int64(int16(val)), err
+	case MYSQL_TYPE_INT24:
+		val, err := readUintLE(r, 3)
+		if val&0x800000 != 0 {
+			val |= 0xffffffffff000000
+		}
+		return int64(val), err
+	case MYSQL_TYPE_LONG:
+		val, err := readUintLE(r, 4)
+		return int64(int32(val)), err
+	case MYSQL_TYPE_LONGLONG:
+		val, err := readUintLE(r, 8)
+		return int64(val), err
+	case MYSQL_TYPE_NULL:
+		return nil, nil
+	case MYSQL_TYPE_FLOAT, MYSQL_TYPE_DOUBLE:
+		return decodeFloat(r, t, int(meta))
+	case MYSQL_TYPE_BIT:
+		// meta is bytes << 8 | bits
+		size := int(meta>>8) + int((meta&0xff+7)/8)
+		if size > 8 {
+			return nil, fmt.Errorf("Invalid BIT length %d", size)
+		}
+
+		return readUintBE(r, size)
+	case MYSQL_TYPE_STRING:
+		real, length := unpackStringMeta(meta)
+		switch real {
+		case MYSQL_TYPE_ENUM:
+			if length != 1 && length != 2 {
+				return nil, fmt.Errorf("Invalid ENUM length %d", length)
+			}
+
+			val, err := readUintLE(r, length)
+			return int64(val), err
+		case MYSQL_TYPE_SET:
+			if length < 1 || length > 8 {
+				return nil, fmt.Errorf("Invalid SET length %d", length)
+			}
+
+			return readUintLE(r, length)
+		}
+
+		size := 1
+		if length > 255 {
+			size = 2
+		}
+
+		val, err := readLengthPrefixed(r, size)
+		return string(val), err
+	case MYSQL_TYPE_VARCHAR, MYSQL_TYPE_VAR_STRING:
+		size := 1
+		if meta >= 256 {
+			size = 2
+		}
+
+		val, err := readLengthPrefixed(r, size)
+		return string(val), err
+	case MYSQL_TYPE_BLOB, MYSQL_TYPE_TINY_BLOB, MYSQL_TYPE_MEDIUM_BLOB,
+		MYSQL_TYPE_LONG_BLOB, MYSQL_TYPE_JSON:
+		return readLengthPrefixed(r, int(meta))
+	case MYSQL_TYPE_GEOMETRY:
+		val, err := readLengthPrefixed(r, int(meta))
+		if err != nil {
+			return nil, err
+		}
+		return decodeGeometry(val)
+	case MYSQL_TYPE_TYPED_ARRAY:
+		// the binary JSON with a 4 bytes length like LONGBLOB
+		val, err := readLengthPrefixed(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		return decodeTypedArray(val), nil
+	case MYSQL_TYPE_NEWDECIMAL:
+		return decodeDecimal(r, int(meta>>8), int(meta&0xff))
+	case MYSQL_TYPE_YEAR:
+		val, err := readUintLE(r, 1)
+		if err != nil || val == 0 {
+			return int64(0), err
+		}
+		return int64(val) + 1900, nil
+	case MYSQL_TYPE_DATE, MYSQL_TYPE_NEWDATE:
+		return decodeDate(r)
+	case MYSQL_TYPE_DATETIME:
+		return decodeDatetime(r)
+	case MYSQL_TYPE_TIME:
+		return decodeTime(r)
+	case MYSQL_TYPE_TIMESTAMP:
+		val, err := readUintLE(r, 4)
+		return time.Unix(int64(val), 0).UTC(), err
+	case MYSQL_TYPE_TIMESTAMP2:
+		return decodeTimestamp2(r, int(meta))
+	case MYSQL_TYPE_DATETIME2:
+		return decodeDatetime2(r, int(meta))
+	case MYSQL_TYPE_TIME2:
+		return decodeTime2(r, int(meta))
+	default:
+		return nil, fmt.Errorf("Unsupported column type %v", t)
+	}
+}
+
+// decodeFloat decodes a FLOAT or DOUBLE, the meta is its size in bytes,
+// 4 for the IEEE 754 single and 8 for the double precision
+func decodeFloat(r *bytes.Reader, t ColumnType, size int) (Any, error) {
+	if size == 0 {
+		size = 8
+		if t == MYSQL_TYPE_FLOAT {
+			size = 4
+		}
+	}
+
+	switch size {
+	case 4:
+		val, err := readUintLE(r, 4)
+		return math.Float32frombits(uint32(val)), err
+	case 8:
+		val, err := readUintLE(r, 8)
+		return math.Float64frombits(val), err
+	default:
+		return nil, fmt.Errorf("Invalid %v length %d", t, size)
+	}
+}
+
+// toUnsigned converts an integer decoded as signed to the value of an unsigned
+// column of type t
+func toUnsigned(v int64, t ColumnType) uint64 {
+	switch t {
+	case MYSQL_TYPE_TINY:
+		return uint64(uint8(v))
+	case MYSQL_TYPE_SHORT:
+		return uint64(uint16(v))
+	case MYSQL_TYPE_INT24:
+		return uint64(v) & 0xffffff
+	case MYSQL_TYPE_LONG:
+		return uint64(uint32(v))
+	default:
+		return uint64(v)
+	}
+}
+
+func readLengthPrefixed(r *bytes.Reader, size int) ([]byte, error) {
+	if size < 1 || size > 4 {
+		return nil, fmt.Errorf("Invalid length size %d", size)
+	}
+
+	length, err := readUintLE(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	val := make([]byte, length)
+	_, err = readFull(r, val)
+	return val, err
+}
+
+var dig2bytes = []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// the limits of DECIMAL(M,D), see DECIMAL_MAX_PRECISION and DECIMAL_MAX_SCALE
+const (
+	DECIMAL_MAX_PRECISION = 65
+	DECIMAL_MAX_SCALE     = 30
+)
+
+// decodeDecimal decodes the binary DECIMAL format, see bin2decimal in
+// strings/decimal.c. The precision and the scale come from the table map or
+// the user variable and are checked, since they size the value.
+func decodeDecimal(r *bytes.Reader, precision int, scale int) (string, error) {
+	if precision <= 0 || precision > DECIMAL_MAX_PRECISION || scale < 0 || scale > precision ||
+		scale > DECIMAL_MAX_SCALE {
+		return "", fmt.Errorf("Invalid DECIMAL(%d,%d)", precision, scale)
+	}
+
+	intg := precision - scale
+	intg0, intg0x := intg/9, intg%9
+	frac0, frac0x := scale/9, scale%9
+	size := intg0*4 + dig2bytes[intg0x] + frac0*4 + dig2bytes[frac0x]
+	buf := make([]byte, size)
+	if _, err := readFull(r, buf); err != nil {
+		return "", err
+	}
+
+	negative := buf[0]&0x80 == 0
+	buf[0] ^= 0x80
+	if negative {
+		for i := range buf {
+			buf[i] ^= 0xff
+		}
+	}
+
+	be := func(b []byte) uint64 {
+		var val uint64
+		for _, c := range b {
+			val = val<<8 | uint64(c)
+		}
+		return val
+	}
+
+	var sb strings.Builder
+	if negative {
+		sb.WriteByte('-')
+	}
+
+	pos := 0
+	var intpart string
+	if intg0x > 0 {
+		intpart = strconv.FormatUint(be(buf[:dig2bytes[intg0x]]), 10)
+		pos = dig2bytes[intg0x]
+	}
+
+	for i := 0; i < intg0; i++ {
+		intpart += fmt.Sprintf("%09d", be(buf[pos:pos+4]))
+		pos += 4
+	}
+
+	intpart = strings.TrimLeft(intpart, "0")
+	if intpart == "" {
+		intpart = "0"
+	}
+
+	sb.WriteString(intpart)
+	if scale > 0 {
+		sb.WriteByte('.')
+		for i := 0; i < frac0; i++ {
+			sb.WriteString(fmt.Sprintf("%09d", be(buf[pos:pos+4])))
+			pos += 4
+		}
+
+		if frac0x > 0 {
+			sb.WriteString(fmt.Sprintf("%0*d", frac0x, be(buf[pos:pos+dig2bytes[frac0x]])))
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// readFraction reads the fractional seconds part of the temporal types
+// in microseconds
+func readFraction(r *bytes.Reader, fsp int) (int64, error) {
+	switch fsp {
+	case 1, 2:
+		val, err := readUintBE(r, 1)
+		return int64(val) * 10000, err
+	case 3, 4:
+		val, err := readUintBE(r, 2)
+		return int64(val) * 100, err
+	case 5, 6:
+		val, err := readUintBE(r, 3)
+		return int64(val), err
+	default:
+		return 0, nil
+	}
+}
+
+func formatFraction(usec int64, fsp int) string {
+	if fsp <= 0 || fsp > 6 {
+		return ""
+	}
+
+	return "." + fmt.Sprintf("%06d", usec)[:fsp]
+}
+
+func decodeTimestamp2(r *bytes.Reader, fsp int) (time.Time, error) {
+	sec, err := readUintBE(r, 4)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	usec, err := readFraction(r, fsp)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(int64(sec), usec*1000).UTC(), nil
+}
+
+// see TIME_from_longlong_datetime_packed in sql-common/my_time.c
+func decodeDatetime2(r *bytes.Reader, fsp int) (string, error) {
+	val, err := readUintBE(r, 5)
+	if err != nil {
+		return "", err
+	}
+
+	usec, err := readFraction(r, fsp)
+	if err != nil {
+		return "", err
+	}
+
+	intpart := int64(val) - 0x8000000000
+	sign := ""
+	if intpart < 0 {
+		sign = "-"
+		intpart = -intpart
+	}
+
+	ymd := intpart >> 17
+	ym := ymd >> 5
+	hms := intpart % (1 << 17)
+	return fmt.Sprintf("%s%04d-%02d-%02d %02d:%02d:%02d%s", sign, ym/13, ym%13, ymd%(1<<5),
+		hms>>12, (hms>>6)%(1<<6), hms%(1<<6), formatFraction(usec, fsp)), nil
+}
+
+// see my_time_packed_from_binary and TIME_from_longlong_time_packed in
+// sql-common/my_time.c
+func decodeTime2(r *bytes.Reader, fsp int) (string, error) {
+	var packed int64
+	switch fsp {
+	case 1, 2, 3, 4:
+		val, err := readUintBE(r, 3)
+		if err != nil {
+			return "", err
+		}
+
+		size, unit, overflow := 1, int64(10000), int64(0x100)
+		if fsp > 2 {
+			size, unit, overflow = 2, 100, 0x10000
+		}
+
+		frac, err := readUintBE(r, size)
+		if err != nil {
+			return "", err
+		}
+
+		intpart, fracpart := int64(val)-0x800000, int64(frac)
+		if intpart < 0 && fracpart != 0 {
+			intpart++
+			fracpart -= overflow
+		}
+
+		packed = intpart<<24 + fracpart*unit
+	case 5, 6:
+		val, err := readUintBE(r, 6)
+		if err != nil {
+			return "", err
+		}
+
+		packed = int64(val) - 0x800000000000
+	default:
+		val, err := readUintBE(r, 3)
+		if err != nil {
+			return "", err
+		}
+
+		packed = (int64(val) - 0x800000) << 24
+	}
+
+	sign := ""
+	if packed < 0 {
+		sign = "-"
+		packed = -packed
+	}
+
+	hms := packed >> 24
+	usec := packed % (1 << 24)
+	return fmt.Sprintf("%s%02d:%02d:%02d%s", sign, (hms>>12)%(1<<10), (hms>>6)%(1<<6),
+		hms%(1<<6), formatFraction(usec, fsp)), nil
+}
+
+// the legacy temporal formats of MySQL before 5.6.4
+
+// DATE is packed as year << 9 | month << 5 | day in 3 bytes
+func decodeDate(r *bytes.Reader) (string, error) {
+	val, err := readUintLE(r, 3)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%04d-%02d-%02d", val>>9, (val>>5)&0x0f, val&0x1f), nil
+}
+
+// DATETIME is the integer YYYYMMDDhhmmss in 8 bytes
+func decodeDatetime(r *bytes.Reader) (string, error) {
+	val, err := readUintLE(r, 8)
+	if err != nil {
+		return "", err
+	}
+
+	ymd, hms := val/1000000, val%1000000
+	return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", ymd/10000, (ymd/100)%100, ymd%100,
+		hms/10000, (hms/100)%100, hms%100), nil
+}
+
+// TIME is the signed integer hhmmss in 3 bytes
+func decodeTime(r *bytes.Reader) (string, error) {
+	val, err := readUintLE(r, 3)
+	if err != nil {
+		return "", err
+	}
+
+	hms := int64(val)
+	if val&0x800000 != 0 {
+		hms -= 0x1000000
+	}
+
+	sign := ""
+	if hms < 0 {
+		sign = "-"
+		hms = -hms
+	}
+
+	return fmt.Sprintf("%s%02d:%02d:%02d", sign, hms/10000, (hms/100)%100, hms%100), nil
+}
+
+// quoteString quotes s as a SQL string literal, binary data which is not
+// valid UTF-8 is written as a hex literal
+func quoteString(s []byte) string {
+	if !utf8.Valid(s) {
+		return "0x" + hex.EncodeToString(s)
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('\'')
+	for _, c := range s {
+		switch c {
+		case 0:
+			sb.WriteString("\\0")
+		case '\n':
+			sb.WriteString("\\n")
+		case '\r':
+			sb.WriteString("\\r")
+		case 0x1a:
+			sb.WriteString("\\Z")
+		case '\'', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	sb.WriteByte('\'')
+	return sb.String()
+}
+
+// formatValue formats a decoded column value as a SQL literal
+func formatValue(val Any, t ColumnType, meta uint16) string {
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		if t == MYSQL_TYPE_BIT {
+			bits := int(meta>>8)*8 + int(meta&0xff)
+			return fmt.Sprintf("b'%0*b'", bits, v)
+		}
+		return strconv.FormatUint(v, 10)
+	case float32:
+		return formatFloat(float64(v), 32)
+	case float64:
+		return formatFloat(v, 64)
+	case string:
+		if t == MYSQL_TYPE_NEWDECIMAL {
+			return v
+		}
+		return quoteString([]byte(v))
+	case []byte:
+		return quoteString(v)
+	case *Geometry:
+		return v.SQL()
+	case *TypedArray:
+		return v.SQL()
+	case time.Time:
+		// TIMESTAMP is formatted as seconds since unix epoch like mysqlbinlog,
+		// since the time zone of the server is unknown
+		usec := int64(v.Nanosecond() / 1000)
+		return strconv.FormatInt(v.Unix(), 10) + formatFraction(usec, int(meta))
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// formatFloat formats a FLOAT or DOUBLE with the fewest digits which read back
+// to the same value. NaN and the infinities, which MySQL does not store, are
+// quoted since they are not numbers in SQL.
+func formatFloat(v float64, bitSize int) string {
+	text := strconv.FormatFloat(v, 'g', -1, bitSize)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return quoteString([]byte(text))
+	}
+
+	return text
+}
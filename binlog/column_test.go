@@ -0,0 +1,211 @@
+//
+// column_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// columnTest is a column value in the row image and what it decodes to
+type columnTest struct {
+	name  string
+	t     ColumnType
+	meta  uint16
+	data  []byte
+	value Any
+	text  string // formatValue of the value
+}
+
+func testColumns(t *testing.T, tests []columnTest) {
+	t.Helper()
+	for _, test := range tests {
+		r := bytes.NewReader(test.data)
+		val, err := decodeValue(r, test.t, test.meta)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if val != test.value {
+			t.Errorf("%s: got %#v, want %#v", test.name, val, test.value)
+		}
+
+		if r.Len() != 0 {
+			t.Errorf("%s: %d bytes left", test.name, r.Len())
+		}
+
+		if text := formatValue(val, test.t, test.meta); text != test.text {
+			t.Errorf("%s: got %s, want %s", test.name, text, test.text)
+		}
+	}
+}
+
+func TestDecodeBit(t *testing.T) {
+	testColumns(t, []columnTest{
+		// the meta is the whole bytes << 8 | the remaining bits
+		{"BIT(1) 0", MYSQL_TYPE_BIT, 0x0001, []byte{0x00}, uint64(0), "b'0'"},
+		{"BIT(1) 1", MYSQL_TYPE_BIT, 0x0001, []byte{0x01}, uint64(1), "b'1'"},
+		{"BIT(12)", MYSQL_TYPE_BIT, 0x0104, []byte{0x0a, 0xbc}, uint64(0xabc), "b'101010111100'"},
+		{"BIT(12) max", MYSQL_TYPE_BIT, 0x0104, []byte{0x0f, 0xff}, uint64(0xfff), "b'111111111111'"},
+		{"BIT(16)", MYSQL_TYPE_BIT, 0x0200, []byte{0x80, 0x01}, uint64(0x8001), "b'1000000000000001'"},
+		{"BIT(64)", MYSQL_TYPE_BIT, 0x0800, bytes.Repeat([]byte{0xff}, 8), uint64(1<<64 - 1),
+			"b'" + string(bytes.Repeat([]byte{'1'}, 64)) + "'"},
+	})
+}
+
+func TestDecodeEmptyString(t *testing.T) {
+	// the empty values end the row image
+	testColumns(t, []columnTest{
+		{"VARCHAR(20)", MYSQL_TYPE_VARCHAR, 20, []byte{0x00}, "", "''"},
+		{"VARCHAR(300)", MYSQL_TYPE_VARCHAR, 300, []byte{0x00, 0x00}, "", "''"},
+	})
+
+	val, err := decodeValue(bytes.NewReader([]byte{0, 0}), MYSQL_TYPE_BLOB, 2)
+	if b, ok := val.([]byte); err != nil || !ok || len(b) != 0 {
+		t.Errorf("BLOB: got %#v, %v", val, err)
+	}
+}
+
+func TestDecodeDecimal(t *testing.T) {
+	testColumns(t, []columnTest{
+		// the meta is precision << 8 | scale
+		{"DECIMAL(10,2)", MYSQL_TYPE_NEWDECIMAL, 10<<8 | 2, []byte{0x80, 0x00, 0x04, 0xd2, 0x38},
+			"1234.56", "1234.56"},
+		{"DECIMAL(10,2) negative", MYSQL_TYPE_NEWDECIMAL, 10<<8 | 2, []byte{0x7f, 0xff, 0xfb, 0x2d, 0xc7},
+			"-1234.56", "-1234.56"},
+		{"DECIMAL(65,30)", MYSQL_TYPE_NEWDECIMAL, 65<<8 | 30,
+			append([]byte{0x80}, make([]byte, 29)...), "0.000000000000000000000000000000",
+			"0.000000000000000000000000000000"},
+	})
+}
+
+func TestDecodeDecimalInvalid(t *testing.T) {
+	tests := []struct {
+		name             string
+		precision, scale int
+	}{
+		{"precision 0", 0, 0},
+		{"scale above precision", 2, 5},
+		{"precision above 65", 66, 0},
+		{"scale above 30", 40, 31},
+		{"negative scale", 10, -1},
+	}
+
+	for _, test := range tests {
+		data := bytes.Repeat([]byte{0x80}, 64)
+		if val, err := decodeDecimal(bytes.NewReader(data), test.precision, test.scale); err == nil {
+			t.Errorf("%s: got %s, want an error", test.name, val)
+		}
+	}
+
+	// DECIMAL(0,0) in the table map
+	if _, err := decodeValue(bytes.NewReader([]byte{0x80}), MYSQL_TYPE_NEWDECIMAL, 0); err == nil {
+		t.Error("DECIMAL(0,0): want an error")
+	}
+
+	// a user variable of DECIMAL(2,5) is formatted raw
+	event := &UserVarEvent{Type: DECIMAL_RESULT, Value: []byte{2, 5, 0x80, 0x01}}
+	if text := event.FormatValue(); text != "02058001" {
+		t.Errorf("user variable: got %s", text)
+	}
+}
+
+func TestUnpackStringMeta(t *testing.T) {
+	tests := []struct {
+		name   string
+		meta   uint16
+		real   ColumnType
+		length int
+	}{
+		{"CHAR(10) latin1", 0xfe0a, MYSQL_TYPE_STRING, 10},
+		{"CHAR(255) latin1", 0xfeff, MYSQL_TYPE_STRING, 255},
+		// the length 1020 is 0x3fc, its bits 8 and 9 are inverted in the type
+		{"CHAR(255) utf8mb4", 0xcefc, MYSQL_TYPE_STRING, 1020},
+		{"CHAR(100) utf8mb4", 0xee90, MYSQL_TYPE_STRING, 400},
+		{"BINARY(16)", 0xfe10, MYSQL_TYPE_STRING, 16},
+		{"ENUM", 0xf701, MYSQL_TYPE_ENUM, 1},
+		{"ENUM of 300 members", 0xf702, MYSQL_TYPE_ENUM, 2},
+		{"SET", 0xf801, MYSQL_TYPE_SET, 1},
+	}
+
+	for _, test := range tests {
+		real, length := unpackStringMeta(test.meta)
+		if real != test.real || length != test.length {
+			t.Errorf("%s: got %v(%d), want %v(%d)", test.name, real, length, test.real, test.length)
+		}
+	}
+}
+
+func TestDecodeChar(t *testing.T) {
+	long := string(bytes.Repeat([]byte{'x'}, 300))
+	testColumns(t, []columnTest{
+		{"CHAR(255) latin1", MYSQL_TYPE_STRING, 0xfeff, []byte{0x03, 'a', 'b', 'c'}, "abc", "'abc'"},
+		{"CHAR(255) latin1 full", MYSQL_TYPE_STRING, 0xfeff, append([]byte{0xff}, bytes.Repeat([]byte{'y'}, 255)...),
+			string(bytes.Repeat([]byte{'y'}, 255)), "'" + string(bytes.Repeat([]byte{'y'}, 255)) + "'"},
+		// the length is in 2 bytes since the max length is over 255 bytes
+		{"CHAR(255) utf8mb4", MYSQL_TYPE_STRING, 0xcefc, []byte{0x03, 0x00, 'a', 'b', 'c'}, "abc", "'abc'"},
+		{"CHAR(255) utf8mb4 long", MYSQL_TYPE_STRING, 0xcefc, append([]byte{0x2c, 0x01}, long...), long, "'" + long + "'"},
+		{"CHAR(255) utf8mb4 empty", MYSQL_TYPE_STRING, 0xcefc, []byte{0x00, 0x00}, "", "''"},
+		{"ENUM", MYSQL_TYPE_STRING, 0xf701, []byte{0x02}, int64(2), "2"},
+		{"SET", MYSQL_TYPE_STRING, 0xf801, []byte{0x05}, uint64(5), "5"},
+	})
+}
+
+func TestDecodeFloat(t *testing.T) {
+	testColumns(t, []columnTest{
+		// the meta is the size in bytes
+		{"FLOAT 0.1", MYSQL_TYPE_FLOAT, 4, []byte{0xcd, 0xcc, 0xcc, 0x3d}, float32(0.1), "0.1"},
+		{"FLOAT -1.5e-07", MYSQL_TYPE_FLOAT, 4, []byte{0xb0, 0x0f, 0x21, 0xb4}, float32(-1.5e-7), "-1.5e-07"},
+		{"FLOAT max", MYSQL_TYPE_FLOAT, 4, []byte{0xff, 0xff, 0x7f, 0x7f}, float32(math.MaxFloat32), "3.4028235e+38"},
+		{"DOUBLE 0.1", MYSQL_TYPE_DOUBLE, 8, []byte{0x9a, 0x99, 0x99, 0x99, 0x99, 0x99, 0xb9, 0x3f}, 0.1, "0.1"},
+		{"DOUBLE 1/3", MYSQL_TYPE_DOUBLE, 8, []byte{0x55, 0x55, 0x55, 0x55, 0x55, 0x55, 0xd5, 0x3f}, 1.0 / 3,
+			"0.3333333333333333"},
+		{"DOUBLE -0", MYSQL_TYPE_DOUBLE, 8, []byte{0, 0, 0, 0, 0, 0, 0, 0x80}, math.Copysign(0, -1), "-0"},
+		{"DOUBLE +Inf", MYSQL_TYPE_DOUBLE, 8, []byte{0, 0, 0, 0, 0, 0, 0xf0, 0x7f}, math.Inf(1), "'+Inf'"},
+	})
+
+	// NaN is not equal to itself
+	val, err := decodeValue(bytes.NewReader([]byte{0x01, 0x00, 0xc0, 0x7f}), MYSQL_TYPE_FLOAT, 4)
+	if f, ok := val.(float32); err != nil || !ok || !math.IsNaN(float64(f)) {
+		t.Errorf("FLOAT NaN: got %#v, %v", val, err)
+	} else if text := formatValue(val, MYSQL_TYPE_FLOAT, 4); text != "'NaN'" {
+		t.Errorf("FLOAT NaN: got %s", text)
+	}
+
+	if _, err = decodeValue(bytes.NewReader(make([]byte, 8)), MYSQL_TYPE_DOUBLE, 5); err == nil {
+		t.Errorf("DOUBLE of 5 bytes: no error")
+	}
+}
+
+// the table map of `test`.`f` (f FLOAT, d DOUBLE) and the rows (0.1, 0.1) and
+// (-1.5e-07, NULL)
+const rowsFloat = `
+	# TABLE_MAP_EVENT at 123
+	80 4c 94 5d 13 01 00 00 00 2f 00 00 00 aa 00 00 00 00 00 56 00 00 00 00
+	00 01 00 04 74 65 73 74 00 01 66 00 02 04 05 02 04 08 03 3f 29 d3 5e
+	# WRITE_ROWS_EVENT at 170
+	80 4c 94 5d 1e 01 00 00 00 35 00 00 00 df 00 00 00 00 00 56 00 00 00 00
+	00 01 00 02 00 02 03 00 cd cc cc 3d 9a 99 99 99 99 99 b9 3f 02 b0 0f 21
+	b4 93 9b f5 37
+`
+
+func TestFloatRowsSQL(t *testing.T) {
+	stmts, err := readEvents(t, fde80+rowsFloat)[2].(*RowsEvent).SQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"INSERT INTO `test`.`f` (@1, @2) VALUES (0.1, 0.1)",
+		"INSERT INTO `test`.`f` (@1, @2) VALUES (-1.5e-07, NULL)",
+	}
+	if !reflect.DeepEqual(stmts, want) {
+		t.Errorf("got %q, want %q", stmts, want)
+	}
+}
@@ -0,0 +1,126 @@
+//
+// diff.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// DiffOptions tells Diff which volatile header fields to ignore
+type DiffOptions struct {
+	IgnoreTimestamp bool
+	IgnoreLogPos    bool // log_pos and event_size
+	IgnoreServerId  bool
+	IgnoreFlags     bool
+}
+
+// Difference is an event which differs between two binlogs, Index is the
+// index of the event in both binlogs and Fields the names of the differing
+// header, post header and payload fields. Fields is "event" if one of the
+// binlogs ends before the other, Type is the type of the event of the other.
+type Difference struct {
+	Index  int
+	Type   LogEventType
+	Fields []string
+}
+
+// Diff compares the events of two binlogs one by one until both end
+func Diff(a, b *Parser) ([]Difference, error) {
+	return DiffWithOptions(a, b, DiffOptions{})
+}
+
+func DiffWithOptions(a, b *Parser, opts DiffOptions) ([]Difference, error) {
+	var diffs []Difference
+	for i := 0; ; i++ {
+		ea, err := a.ReadEvent()
+		if err != nil && err != io.EOF {
+			return diffs, err
+		}
+
+		eb, err := b.ReadEvent()
+		if err != nil && err != io.EOF {
+			return diffs, err
+		}
+
+		switch {
+		case ea == nil && eb == nil:
+			return diffs, nil
+		case ea == nil:
+			diffs = append(diffs, Difference{i, eb.Header().EventType, []string{"event"}})
+		case eb == nil:
+			diffs = append(diffs, Difference{i, ea.Header().EventType, []string{"event"}})
+		default:
+			if fields := diffEvent(ea, eb, opts); fields != nil {
+				diffs = append(diffs, Difference{i, ea.Header().EventType, fields})
+			}
+		}
+	}
+}
+
+func diffEvent(a, b BinLogEvent, opts DiffOptions) []string {
+	ha, hb := a.Header(), b.Header()
+	var fields []string
+	if ha.EventType != hb.EventType {
+		return []string{"event_type"}
+	}
+
+	if !opts.IgnoreTimestamp && ha.Timestamp != hb.Timestamp {
+		fields = append(fields, "timestamp")
+	}
+
+	if !opts.IgnoreServerId && ha.ServerId != hb.ServerId {
+		fields = append(fields, "server_id")
+	}
+
+	if !opts.IgnoreLogPos && ha.EventSize != hb.EventSize {
+		fields = append(fields, "event_size")
+	}
+
+	if !opts.IgnoreLogPos && ha.LogPos != hb.LogPos {
+		fields = append(fields, "log_pos")
+	}
+
+	if !opts.IgnoreFlags && ha.Flags != hb.Flags {
+		fields = append(fields, "flags")
+	}
+
+	fields = append(fields, diffLines(a.GetPostHeader(), b.GetPostHeader())...)
+	return append(fields, diffLines(a.GetPayload(), b.GetPayload())...)
+}
+
+// diffLines returns the names of the "name: value" lines which are not in
+// both a and b. The lines are compared regardless of the order since some
+// events, e.g. the status variables of QUERY_EVENT, are printed in random
+// order.
+func diffLines(a, b []string) []string {
+	count := make(map[string]int)
+	for _, line := range a {
+		count[line]++
+	}
+
+	for _, line := range b {
+		count[line]--
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for line, n := range count {
+		if n == 0 {
+			continue
+		}
+
+		name := strings.TrimSpace(strings.SplitN(line, ":", 2)[0])
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,57 @@
+//
+// errcode.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Names of the common MySQL server error codes, from include/mysqld_error.h
+//
+
+package binlog
+
+var errorNames = map[uint16]string{
+	1005: "ER_CANT_CREATE_TABLE",
+	1006: "ER_CANT_CREATE_DB",
+	1007: "ER_DB_CREATE_EXISTS",
+	1008: "ER_DB_DROP_EXISTS",
+	1022: "ER_DUP_KEY",
+	1030: "ER_GET_ERRNO",
+	1032: "ER_KEY_NOT_FOUND",
+	1036: "ER_OPEN_AS_READONLY",
+	1044: "ER_DBACCESS_DENIED_ERROR",
+	1045: "ER_ACCESS_DENIED_ERROR",
+	1046: "ER_NO_DB_ERROR",
+	1048: "ER_BAD_NULL_ERROR",
+	1049: "ER_BAD_DB_ERROR",
+	1050: "ER_TABLE_EXISTS_ERROR",
+	1051: "ER_BAD_TABLE_ERROR",
+	1052: "ER_NON_UNIQ_ERROR",
+	1053: "ER_SERVER_SHUTDOWN",
+	1054: "ER_BAD_FIELD_ERROR",
+	1060: "ER_DUP_FIELDNAME",
+	1061: "ER_DUP_KEYNAME",
+	1062: "ER_DUP_ENTRY",
+	1064: "ER_PARSE_ERROR",
+	1091: "ER_CANT_DROP_FIELD_OR_KEY",
+	1105: "ER_UNKNOWN_ERROR",
+	1114: "ER_RECORD_FILE_FULL",
+	1142: "ER_TABLEACCESS_DENIED_ERROR",
+	1146: "ER_NO_SUCH_TABLE",
+	1205: "ER_LOCK_WAIT_TIMEOUT",
+	1213: "ER_LOCK_DEADLOCK",
+	1216: "ER_NO_REFERENCED_ROW",
+	1217: "ER_ROW_IS_REFERENCED",
+	1227: "ER_SPECIFIC_ACCESS_DENIED_ERROR",
+	1264: "ER_WARN_DATA_OUT_OF_RANGE",
+	1317: "ER_QUERY_INTERRUPTED",
+	1364: "ER_NO_DEFAULT_FOR_FIELD",
+	1396: "ER_CANNOT_USER",
+	1451: "ER_ROW_IS_REFERENCED_2",
+	1452: "ER_NO_REFERENCED_ROW_2",
+	1690: "ER_DATA_OUT_OF_RANGE",
+	3024: "ER_QUERY_TIMEOUT",
+}
+
+// ErrorName returns the name of a MySQL error code, or an empty string if
+// the code is not a common one
+func ErrorName(code uint16) string {
+	return errorNames[code]
+}
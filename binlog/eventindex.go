@@ -0,0 +1,43 @@
+//
+// eventindex.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Index of the events of a binlog for random access with SeekToPos
+//
+
+package binlog
+
+import (
+	"io"
+)
+
+// EventIndexEntry locates an event in the binlog
+type EventIndexEntry struct {
+	Offset    int64        `json:"offset"`
+	Type      LogEventType `json:"type"`
+	Timestamp uint32       `json:"timestamp"`
+	Size      uint32       `json:"size"`
+}
+
+// BuildIndex reads the headers of the rest of the binlog and returns where
+// each event is, so that an event can be read later by SeekToPos without
+// reading the binlog again. The events are skipped by SkipEvent, i.e. only
+// the FORMAT_DESCRIPTION_EVENT is decoded and the bodies are skipped by
+// seeking if the reader is an io.Seeker. The entries read before an error are
+// returned with it.
+func (self *Parser) BuildIndex() ([]EventIndexEntry, error) {
+	var entries []EventIndexEntry
+	for {
+		pos := self.pos
+		if err := self.SkipEvent(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+
+			return entries, err
+		}
+
+		header := self.last
+		entries = append(entries, EventIndexEntry{pos, header.EventType, header.Timestamp, header.EventSize})
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/hashicorp/go-version"
 	"io"
 	"strings"
@@ -17,28 +19,65 @@ import (
 )
 
 type BinLogEventHeader struct {
-	Timestamp uint32 //  seconds since unix epoch
-	EventType LogEventType
-	ServerId  uint32 // server-id of the originating mysql-server. Used to filter out events in circular replication.
-	EventSize uint32 // size of the event (header, post-header, body)
-	LogPos    uint32 // position of the next event
-	Flags     uint16
+	Timestamp uint32        `json:"timestamp"` //  seconds since unix epoch
+	EventType LogEventType  `json:"event_type"`
+	ServerId  uint32        `json:"server_id"`  // server-id of the originating mysql-server. Used to filter out events in circular replication.
+	EventSize uint32        `json:"event_size"` // size of the event (header, post-header, body)
+	LogPos    uint32        `json:"log_pos"`    // position of the next event
+	Flags     LogEventFlags `json:"flags"`
+}
+
+// the time zone of the timestamps shown by Desc and GetPayload
+var timeLocation = time.Local
+
+// SetTimeLocation sets the time zone the timestamps of the events are shown
+// in by Desc and GetPayload, e.g. time.UTC for the output which does not
+// depend on the machine, local time by default. It is not safe to call while
+// the events are formatted.
+func SetTimeLocation(loc *time.Location) {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	timeLocation = loc
+}
+
+// TimeLocation returns the time zone set by SetTimeLocation
+func TimeLocation() *time.Location {
+	return timeLocation
+}
+
+// Time returns the timestamp of the event in the time zone loc, nil is UTC
+func (header *BinLogEventHeader) Time(loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	return time.Unix(int64(header.Timestamp), 0).In(loc)
 }
 
 func (header *BinLogEventHeader) Desc() []string {
 	return []string{
-		fmt.Sprintf("timestamp: %d (%v)", header.Timestamp, time.Unix(int64(header.Timestamp), 0)),
+		fmt.Sprintf("timestamp: %d (%v)", header.Timestamp, header.Time(timeLocation)),
 		fmt.Sprintf("event_type: %v", header.EventType),
 		fmt.Sprintf("server_id: %d", header.ServerId),
 		fmt.Sprintf("event_size: %d", header.EventSize),
 		fmt.Sprintf("log_pos: %d", header.LogPos),
-		fmt.Sprintf("flags: %d", header.Flags),
+		fmt.Sprintf("flags: %s", header.formatFlags()),
+	}
+}
+
+func (header *BinLogEventHeader) formatFlags() string {
+	if header.Flags == 0 {
+		return "0"
 	}
+
+	return fmt.Sprintf("0x%x (%v)", uint16(header.Flags), header.Flags)
 }
 
 func NewBinLogEventHeader(text []byte) (*BinLogEventHeader, error) {
 	if len(text) != BINLOG_EVENT_HEADER_LEN {
-		panic("Invalid binlog event header")
+		return nil, errors.New("Invalid binlog event header")
 	}
 
 	reader := bytes.NewReader(text)
@@ -48,6 +87,7 @@ func NewBinLogEventHeader(text []byte) (*BinLogEventHeader, error) {
 }
 
 type BinLogEvent interface {
+	Header() *BinLogEventHeader
 	GetHeader() []string
 	GetPostHeader() []string
 	GetPayload() []string
@@ -57,6 +97,10 @@ type UnknownBinLogEvent struct {
 	header *BinLogEventHeader
 }
 
+func (event *UnknownBinLogEvent) Header() *BinLogEventHeader {
+	return event.header
+}
+
 func (event *UnknownBinLogEvent) GetHeader() []string {
 	return event.header.Desc()
 }
@@ -66,15 +110,68 @@ func (event *UnknownBinLogEvent) GetPostHeader() []string {
 }
 
 func (event *UnknownBinLogEvent) GetPayload() []string {
+	if event.Ignorable() {
+		return []string{"ignorable: true"}
+	}
+
+	return nil
+}
+
+// Ignorable reports whether the event has LOG_EVENT_IGNORABLE_F, i.e. the
+// server wrote it to be skipped by the readers which do not know its type,
+// so unlike the other unknown events it does not suggest a newer server or
+// a corrupt binlog
+func (event *UnknownBinLogEvent) Ignorable() bool {
+	return event.header.Flags&LOG_EVENT_IGNORABLE_F != 0
+}
+
+// IGNORABLE_LOG_EVENT, which carries nothing the replica needs, the body is
+// not decoded
+type IgnorableLogEvent struct {
+	header *BinLogEventHeader
+	size   int
+}
+
+func (self *IgnorableLogEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *IgnorableLogEvent) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *IgnorableLogEvent) GetPostHeader() []string {
 	return nil
 }
 
+func (self *IgnorableLogEvent) GetPayload() []string {
+	return []string{fmt.Sprintf("data_length: %d", self.size)}
+}
+
+func newIgnorableLogEvent(header *BinLogEventHeader, text []byte,
+	fde *FormatDescriptionEvent) (*IgnorableLogEvent, error) {
+
+	size := len(text)
+	if fde.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
+		size -= BINLOG_CHECKSUM_LEN
+	}
+
+	if size < 0 {
+		return nil, errors.New("Invalid IgnorableLogEvent len")
+	}
+
+	return &IgnorableLogEvent{header, size}, nil
+}
+
 type FormatDescriptionEventPayload struct {
 	BinlogVersion         uint16 // version of this binlog format
 	MySQLServerVersion    string // version of the MySQL Server that created the binlog(50 bytes)
 	CreateTimestamp       uint32 // seconds since Unix epoch when the binlog was created
 	EventHeaderLength     uint8  // length of the Binlog Event Header of next events. Should always be 19
 	EventTypeHeaderLength []byte // a array indexed by Binlog Event Type - 1 to extract the length of the event specific header
+
+	// EventTypeHeaderLength by event type
+	PostHeaderLength map[LogEventType]uint8
 }
 
 func (payload *FormatDescriptionEventPayload) Desc() []string {
@@ -93,6 +190,10 @@ type FormatDescriptionEvent struct {
 	ChecksumAlg BinlogChecksumAlg
 }
 
+func (event *FormatDescriptionEvent) Header() *BinLogEventHeader {
+	return event.header
+}
+
 func (event *FormatDescriptionEvent) GetHeader() []string {
 	return event.header.Desc()
 }
@@ -102,20 +203,82 @@ func (event *FormatDescriptionEvent) GetPostHeader() []string {
 }
 
 func (event *FormatDescriptionEvent) GetPayload() []string {
-	return event.payload.Desc()
+	return append(event.payload.Desc(), fmt.Sprintf("checksum_alg: %v", event.ChecksumAlg))
+}
+
+// PostHeaderLen returns the post header length of the events of type t as
+// written by the server, or def if the FDE is not read yet or does not know
+// the type
+func (event *FormatDescriptionEvent) PostHeaderLen(t LogEventType, def int) int {
+	if event.payload != nil {
+		if length, ok := event.payload.PostHeaderLength[t]; ok {
+			return int(length)
+		}
+	}
+
+	return def
+}
+
+// FormatDescriptionSummary is the format of a binlog as described by its
+// FormatDescriptionEvent, ChecksumAware is false if the server is older than
+// 5.6.1 and writes no checksum
+type FormatDescriptionSummary struct {
+	BinlogVersion   uint16 `json:"binlog_version"`
+	ServerVersion   string `json:"server_version"`
+	ChecksumAlg     string `json:"checksum_alg"`
+	ChecksumAware   bool   `json:"checksum_aware"`
+	CreateTimestamp uint32 `json:"create_timestamp"`
+}
+
+// Summary returns the versions and the checksum algorithm of the binlog, the
+// versions are empty if the FDE is the default one of a binlog without FDE
+func (event *FormatDescriptionEvent) Summary() *FormatDescriptionSummary {
+	summary := &FormatDescriptionSummary{ChecksumAlg: event.ChecksumAlg.String()}
+	if event.payload == nil {
+		return summary
+	}
+
+	summary.BinlogVersion = event.payload.BinlogVersion
+	summary.ServerVersion = event.payload.MySQLServerVersion
+	summary.CreateTimestamp = event.payload.CreateTimestamp
+	if v, err := parseServerVersion(summary.ServerVersion); err == nil {
+		summary.ChecksumAware = v.GreaterThanOrEqual(checksumVersion)
+	}
+
+	return summary
 }
 
+// the first version which writes the checksum algorithm in the FDE
+var checksumVersion = version.Must(version.NewVersion("5.6.1"))
+
+// parseServerVersion parses the leading X.Y.Z of a server version, the rest
+// like -log, -debug or the vendor tags as in 5.7.26-29-log is ignored
+func parseServerVersion(s string) (*version.Version, error) {
+	end := 0
+	for end < len(s) && (s[end] == '.' || (s[end] >= '0' && s[end] <= '9')) {
+		end++
+	}
+
+	return version.NewVersion(strings.Trim(s[:end], "."))
+}
+
+// the binlog version, the server version, the create timestamp and the
+// common header length before the post header lengths of the FDE
+const FORMAT_DESCRIPTION_FIXED_LEN = 2 + 50 + 4 + 1
+
 func newFormatDescriptionEventPayload(
 	header *BinLogEventHeader, text []byte) (*FormatDescriptionEventPayload, BinlogChecksumAlg, error) {
 
+	// a truncated FDE would fail the reads below with io.EOF, which is taken
+	// as the end of the binlog
 	size := header.EventSize - BINLOG_EVENT_HEADER_LEN
-	if size != uint32(len(text)) {
-		panic("Invalid FormatDescriptionEventPayload len")
+	if size != uint32(len(text)) || size < FORMAT_DESCRIPTION_FIXED_LEN {
+		return nil, 0, errors.New("Invalid FormatDescriptionEventPayload len")
 	}
 
 	r := bytes.NewReader(text)
 	payload := new(FormatDescriptionEventPayload)
-	err := binary.Read(r, binary.LittleEndian, payload.BinlogVersion)
+	err := binary.Read(r, binary.LittleEndian, &payload.BinlogVersion)
 	if err != nil {
 		return nil, BINLOG_CHECKSUM_ALG_OFF, err
 	}
@@ -136,31 +299,59 @@ func newFormatDescriptionEventPayload(
 		payload.MySQLServerVersion = string(sversion)
 	}
 
-	if err = binary.Read(r, binary.LittleEndian, (*payload).CreateTimestamp); err != nil {
+	if err = binary.Read(r, binary.LittleEndian, &payload.CreateTimestamp); err != nil {
 		return nil, BINLOG_CHECKSUM_ALG_OFF, err
 	}
 
-	if err = binary.Read(r, binary.LittleEndian, payload.EventHeaderLength); err != nil {
+	if err = binary.Read(r, binary.LittleEndian, &payload.EventHeaderLength); err != nil {
 		return nil, BINLOG_CHECKSUM_ALG_OFF, err
 	}
 
+	// The post header length of the FDE itself in the array tells where the
+	// payload ends, any 5 bytes left are the checksum algorithm and checksum.
+	// The server version decides only when the length does not match, a
+	// version which can not be parsed is taken as a server without checksum.
 	alg := BINLOG_CHECKSUM_ALG_OFF
-	if version.Must(version.NewVersion(payload.MySQLServerVersion)).GreaterThanOrEqual(
-		version.Must(version.NewVersion("5.6.1"))) {
+	hasAlg := false
+	if idx := FORMAT_DESCRIPTION_FIXED_LEN + int(FORMAT_DESCRIPTION_EVENT) - 1; idx < len(text) {
+		switch int(text[idx]) {
+		case len(text) - BINLOG_CHECKSUM_LEN - BINLOG_CHECKSUM_ALG_LEN:
+			hasAlg = true
+		case len(text):
+			hasAlg = false
+		default:
+			v, err := parseServerVersion(payload.MySQLServerVersion)
+			hasAlg = err == nil && v.GreaterThanOrEqual(checksumVersion)
+		}
+	}
 
+	// A checksum aware server writes the algorithm and the checksum of the FDE
+	// even with binlog_checksum=NONE, the algorithm is OFF then and only the
+	// following events have no checksum, so the 5 bytes are not part of the
+	// post header lengths whatever the algorithm is.
+	if hasAlg {
 		alg = BinlogChecksumAlg(text[len(text)-BINLOG_CHECKSUM_LEN-BINLOG_CHECKSUM_ALG_LEN])
 		if alg >= BINLOG_CHECKSUM_ALG_END {
-			panic("Invalid checksum algorithm")
+			return nil, alg, errors.New("Invalid checksum algorithm")
+		}
+
+		if size < FORMAT_DESCRIPTION_FIXED_LEN+BINLOG_CHECKSUM_LEN+BINLOG_CHECKSUM_ALG_LEN {
+			return nil, alg, errors.New("Invalid FormatDescriptionEventPayload len")
 		}
 
 		size -= (BINLOG_CHECKSUM_LEN + BINLOG_CHECKSUM_ALG_LEN)
 	}
 
-	payload.EventTypeHeaderLength = make([]byte, size-(2+50+4+1))
+	payload.EventTypeHeaderLength = make([]byte, size-FORMAT_DESCRIPTION_FIXED_LEN)
 	if err = binary.Read(r, binary.LittleEndian, payload.EventTypeHeaderLength); err != nil {
 		return nil, alg, err
 	}
 
+	payload.PostHeaderLength = make(map[LogEventType]uint8)
+	for i, length := range payload.EventTypeHeaderLength {
+		payload.PostHeaderLength[LogEventType(i+1)] = length
+	}
+
 	return payload, alg, err
 }
 
@@ -180,6 +371,10 @@ type XidEvent struct {
 	xid    uint64
 }
 
+func (event *XidEvent) Header() *BinLogEventHeader {
+	return event.header
+}
+
 func (event *XidEvent) GetHeader() []string {
 	return event.header.Desc()
 }
@@ -194,10 +389,16 @@ func (event *XidEvent) GetPayload() []string {
 	}
 }
 
+// Xid returns the id of the transaction in the storage engine, which is in
+// the InnoDB redo log too
+func (event *XidEvent) Xid() uint64 {
+	return event.xid
+}
+
 func newXidEventPayload(header *BinLogEventHeader, text []byte) (xid uint64, err error) {
 	size := header.EventSize - BINLOG_EVENT_HEADER_LEN
 	if size != uint32(len(text)) {
-		panic("Invalid XidEventPayload len")
+		return 0, errors.New("Invalid XidEventPayload len")
 	}
 
 	r := bytes.NewReader(text)
@@ -213,13 +414,20 @@ type QueryEventPostHeader struct {
 	StatusVarsLength uint16
 }
 
+// newQueryEventPostHeader decodes the post header, which is 13 bytes since
+// binlog version 4 and 11 bytes without status_vars_length before. The bytes
+// added by a later version are ignored.
 func newQueryEventPostHeader(text []byte) (*QueryEventPostHeader, error) {
-	if len(text) != QUERY_EVENT_POST_HEADER_LEN {
-		panic("Invalid QueryEventPostHeader len")
+	if len(text) < QUERY_EVENT_POST_HEADER_LEN-2 {
+		return nil, errors.New("Invalid QueryEventPostHeader len")
+	}
+
+	if len(text) < QUERY_EVENT_POST_HEADER_LEN {
+		text = append(text[:len(text):len(text)], 0, 0)
 	}
 
 	post := new(QueryEventPostHeader)
-	r := bytes.NewReader(text)
+	r := bytes.NewReader(text[:QUERY_EVENT_POST_HEADER_LEN])
 	err := binary.Read(r, binary.LittleEndian, post)
 	return post, err
 }
@@ -242,6 +450,17 @@ const (
 	Q_INVOKERS                  QStatusKey = 0x0b
 	Q_UPDATED_DB_NAMES          QStatusKey = 0x0c
 	Q_MICROSECONDS              QStatusKey = 0x0d
+
+	// MySQL 8.0
+	Q_EXPLICIT_DEFAULTS_FOR_TIMESTAMP QStatusKey = 0x10
+	Q_DDL_LOGGED_WITH_XID             QStatusKey = 0x11
+	Q_DEFAULT_COLLATION_FOR_UTF8MB4   QStatusKey = 0x12
+	Q_SQL_REQUIRE_PRIMARY_KEY         QStatusKey = 0x13
+	Q_DEFAULT_TABLE_ENCRYPTION        QStatusKey = 0x14
+
+	// MariaDB
+	Q_HRNOW QStatusKey = 0x80
+	Q_XID   QStatusKey = 0x81
 )
 
 func (self QStatusKey) String() string {
@@ -274,11 +493,32 @@ func (self QStatusKey) String() string {
 		return "Q_UPDATED_DB_NAMES"
 	case Q_MICROSECONDS:
 		return "Q_MICROSECONDS"
+	case Q_EXPLICIT_DEFAULTS_FOR_TIMESTAMP:
+		return "Q_EXPLICIT_DEFAULTS_FOR_TIMESTAMP"
+	case Q_DDL_LOGGED_WITH_XID:
+		return "Q_DDL_LOGGED_WITH_XID"
+	case Q_DEFAULT_COLLATION_FOR_UTF8MB4:
+		return "Q_DEFAULT_COLLATION_FOR_UTF8MB4"
+	case Q_SQL_REQUIRE_PRIMARY_KEY:
+		return "Q_SQL_REQUIRE_PRIMARY_KEY"
+	case Q_DEFAULT_TABLE_ENCRYPTION:
+		return "Q_DEFAULT_TABLE_ENCRYPTION"
+	case Q_HRNOW:
+		return "Q_HRNOW"
+	case Q_XID:
+		return "Q_XID"
 	default:
-		return "UNKNOWN"
+		return fmt.Sprintf("UNKNOWN(0x%02x)", uint8(self))
 	}
 }
 
+// known reports whether the length of the status var is known, the status
+// vars after an unknown one can not be decoded
+func (self QStatusKey) known() bool {
+	return self <= Q_MICROSECONDS || self >= Q_EXPLICIT_DEFAULTS_FOR_TIMESTAMP && self <= Q_DEFAULT_TABLE_ENCRYPTION ||
+		self == Q_HRNOW || self == Q_XID
+}
+
 type QFlags2CodeType uint32
 
 const (
@@ -496,9 +736,16 @@ type QueryEventPayload struct {
 func newQueryEventPayload(header *BinLogEventHeader,
 	postHeader *QueryEventPostHeader, text []byte) (payload *QueryEventPayload, err error) {
 
+	statusLen, schemaLen := int(postHeader.StatusVarsLength), int(postHeader.SchemaLength)
+	if statusLen+schemaLen+1 > len(text) {
+		return nil, fmt.Errorf("Invalid QueryEvent status_vars_length %d and schema_length %d for payload len %d",
+			statusLen, schemaLen, len(text))
+	}
+
 	payload = new(QueryEventPayload)
 	payload.StatusVars = make(map[QStatusKey]Any)
-	r := bytes.NewReader(text)
+	// the status vars can not be read past their length
+	r := bytes.NewReader(text[:statusLen])
 	var key QStatusKey
 	for n := 0; n < int(postHeader.StatusVarsLength); {
 		if err = binary.Read(r, binary.LittleEndian, &key); err != nil {
@@ -519,7 +766,7 @@ func newQueryEventPayload(header *BinLogEventHeader,
 				payload.StatusVars[key] = val
 			}
 			n += 4
-		case Q_SQL_MODE_CODE, Q_TABLE_MAP_FOR_UPDATE_CODE:
+		case Q_SQL_MODE_CODE, Q_TABLE_MAP_FOR_UPDATE_CODE, Q_DDL_LOGGED_WITH_XID, Q_XID:
 			var val uint64
 			if err = binary.Read(r, binary.LittleEndian, &val); err != nil {
 				return
@@ -569,7 +816,7 @@ func newQueryEventPayload(header *BinLogEventHeader,
 			}
 			payload.StatusVars[key] = val
 			n += (1 + int(length))
-		case Q_LC_TIME_NAMES_CODE, Q_CHARSET_DATABASE_CODE:
+		case Q_LC_TIME_NAMES_CODE, Q_CHARSET_DATABASE_CODE, Q_DEFAULT_COLLATION_FOR_UTF8MB4:
 			var val uint16
 			if err = binary.Read(r, binary.LittleEndian, &val); err != nil {
 				return
@@ -623,7 +870,7 @@ func newQueryEventPayload(header *BinLogEventHeader,
 
 			payload.StatusVars[key] = [][]byte{username, hostname}
 			n += (1 + len(username) + 1 + len(hostname))
-		case Q_MICROSECONDS:
+		case Q_MICROSECONDS, Q_HRNOW:
 			val := make([]byte, 3)
 			if err = binary.Read(r, binary.LittleEndian, &val); err != nil {
 				return
@@ -631,9 +878,26 @@ func newQueryEventPayload(header *BinLogEventHeader,
 
 			payload.StatusVars[key] = val
 			n += 3
+		case Q_EXPLICIT_DEFAULTS_FOR_TIMESTAMP, Q_SQL_REQUIRE_PRIMARY_KEY, Q_DEFAULT_TABLE_ENCRYPTION:
+			var val uint8
+			if err = binary.Read(r, binary.LittleEndian, &val); err != nil {
+				return
+			}
+
+			payload.StatusVars[key] = val
+			n += 1
+		default:
+			// the length of an unknown status var is unknown, so the rest
+			// of the status vars are kept undecoded under its key like the
+			// server skips them, instead of being misread as keys
+			val := make([]byte, r.Len())
+			r.Read(val)
+			payload.StatusVars[key] = val
+			n = statusLen
 		}
 	}
 
+	r = bytes.NewReader(text[statusLen:])
 	payload.Schema = make([]byte, postHeader.SchemaLength)
 	if err = binary.Read(r, binary.LittleEndian, &payload.Schema); err != nil {
 		return
@@ -644,7 +908,7 @@ func newQueryEventPayload(header *BinLogEventHeader,
 		return
 	}
 
-	querySize := len(text) - int(postHeader.StatusVarsLength) - int(postHeader.SchemaLength) - 1
+	querySize := len(text) - statusLen - schemaLen - 1
 	payload.Query = make([]byte, querySize)
 	err = binary.Read(r, binary.LittleEndian, &payload.Query)
 	return
@@ -654,6 +918,11 @@ type QueryEvent struct {
 	header     *BinLogEventHeader
 	postHeader *QueryEventPostHeader
 	payload    *QueryEventPayload
+	redact     *RedactOptions
+}
+
+func (self *QueryEvent) Header() *BinLogEventHeader {
+	return self.header
 }
 
 func (self *QueryEvent) GetHeader() []string {
@@ -665,11 +934,19 @@ func (self *QueryEvent) GetPostHeader() []string {
 		fmt.Sprintf("slave_proxy_id: %d", self.postHeader.SlaveProxyId),
 		fmt.Sprintf("execution_time: %d", self.postHeader.ExecutionTime),
 		fmt.Sprintf("schema_length: %d", self.postHeader.SchemaLength),
-		fmt.Sprintf("error_code: %d", self.postHeader.ErrorCode),
+		fmt.Sprintf("error_code: %s", formatErrorCode(self.postHeader.ErrorCode)),
 		fmt.Sprintf("status_vars_length: %d", self.postHeader.StatusVarsLength),
 	}
 }
 
+func formatErrorCode(code uint16) string {
+	if name := ErrorName(code); name != "" {
+		return fmt.Sprintf("%d (%s)", code, name)
+	}
+
+	return fmt.Sprint(code)
+}
+
 func (self *QueryEvent) GetPayload() []string {
 	ret := []string{"status_vars:"}
 	for key, val := range self.payload.StatusVars {
@@ -677,21 +954,61 @@ func (self *QueryEvent) GetPayload() []string {
 	}
 
 	ret = append(ret, fmt.Sprintf("schema:\n%s", hex.Dump(self.payload.Schema)))
-	ret = append(ret, fmt.Sprintf("query:\n%s", hex.Dump(self.payload.Query)))
+	ret = append(ret, fmt.Sprintf("query:\n%s", hex.Dump([]byte(self.query()))))
 	return ret
 }
 
+// ExecutionTime returns how long the statement took on the master in seconds
+func (self *QueryEvent) ExecutionTime() uint32 {
+	return self.postHeader.ExecutionTime
+}
+
+// Schema returns the default database of the query
+func (self *QueryEvent) Schema() string {
+	return string(self.payload.Schema)
+}
+
+// UseStatement returns the USE statement of the default database of the
+// query, with the name quoted since it may have any character but NUL, or an
+// empty string if there is no default database
+func (self *QueryEvent) UseStatement() string {
+	if len(self.payload.Schema) == 0 {
+		return ""
+	}
+
+	return "USE " + quoteName(self.Schema())
+}
+
+func (self *QueryEvent) Query() string {
+	return string(self.payload.Query)
+}
+
+// query returns the query for the output, redacted if the parser is told to
+func (self *QueryEvent) query() string {
+	if self.redact != nil {
+		return RedactQuery(self.Query(), self.redact)
+	}
+
+	return self.Query()
+}
+
+// IsDDL reports whether the query is a DDL statement, detected by its prefix
+func (self *QueryEvent) IsDDL() bool {
+	return isQuery(self, "CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME")
+}
+
+// IsDML reports whether the query is a DML statement, detected by its prefix,
+// which is only logged as a query with the statement based replication
+func (self *QueryEvent) IsDML() bool {
+	return isQuery(self, "INSERT", "UPDATE", "DELETE", "REPLACE", "LOAD DATA")
+}
+
 func newQueryEvent(header *BinLogEventHeader,
 	text []byte, fde *FormatDescriptionEvent) (*QueryEvent, error) {
 
 	size := header.EventSize - BINLOG_EVENT_HEADER_LEN
 	if size != uint32(len(text)) {
-		panic("Invalid QueryEvent")
-	}
-
-	postHeader, err := newQueryEventPostHeader(text[:QUERY_EVENT_POST_HEADER_LEN])
-	if err != nil {
-		return nil, err
+		return nil, errors.New("Invalid QueryEvent len")
 	}
 
 	end := len(text)
@@ -699,18 +1016,31 @@ func newQueryEvent(header *BinLogEventHeader,
 		end -= BINLOG_CHECKSUM_LEN
 	}
 
-	fmt.Printf("eventsize=%d, size=%d, end=%d\n", header.EventSize, size, end)
-	payload, err := newQueryEventPayload(header, postHeader, text[QUERY_EVENT_POST_HEADER_LEN:end])
+	postLen := fde.PostHeaderLen(QUERY_EVENT, QUERY_EVENT_POST_HEADER_LEN)
+	if postLen > end {
+		return nil, errors.New("Invalid QueryEvent len")
+	}
+
+	postHeader, err := newQueryEventPostHeader(text[:postLen])
 	if err != nil {
 		return nil, err
 	}
 
-	return &QueryEvent{header, postHeader, payload}, nil
+	payload, err := newQueryEventPayload(header, postHeader, text[postLen:end])
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryEvent{header, postHeader, payload, nil}, nil
 }
 
 type PreviousGtidsLogEvent struct {
-	header   *BinLogEventHeader
-	gtidSets []GTIDSet
+	header  *BinLogEventHeader
+	gtidSet GTIDSet
+}
+
+func (self *PreviousGtidsLogEvent) Header() *BinLogEventHeader {
+	return self.header
 }
 
 func (self *PreviousGtidsLogEvent) GetHeader() []string {
@@ -722,14 +1052,12 @@ func (self *PreviousGtidsLogEvent) GetPostHeader() []string {
 }
 
 func (self *PreviousGtidsLogEvent) GetPayload() []string {
-	var val []string
-	val = append(val, "gtid_sets:")
-	for _, gtidset := range self.gtidSets {
-		val = append(val, fmt.Sprintf("	%v:%d-%d, interval=%d",
-			gtidset.Gtid, gtidset.From, gtidset.To, gtidset.Interval))
-	}
+	return []string{fmt.Sprintf("gtid_set: %v", self.gtidSet)}
+}
 
-	return val
+// GTIDSet returns the GTIDs executed before the binlog
+func (self *PreviousGtidsLogEvent) GTIDSet() GTIDSet {
+	return self.gtidSet
 }
 
 func newPreviousGtidsLogEvent(header *BinLogEventHeader, text []byte,
@@ -740,19 +1068,206 @@ func newPreviousGtidsLogEvent(header *BinLogEventHeader, text []byte,
 		size -= BINLOG_CHECKSUM_LEN
 	}
 
+	if size < 0 {
+		return nil, errors.New("Invalid PREVIOUS_GTIDS_LOG_EVENT len")
+	}
+
+	// n_sids followed by the sid, n_intervals and the intervals of each sid
 	var count uint64
-	r := bytes.NewReader(text)
+	r := bytes.NewReader(text[:size])
 	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
 		return nil, err
 	}
 
 	event := new(PreviousGtidsLogEvent)
 	event.header = header
-	event.gtidSets = make([]GTIDSet, count)
-	if err := binary.Read(r, binary.LittleEndian, &event.gtidSets); err != nil {
+	event.gtidSet = make(GTIDSet)
+	for i := uint64(0); i < count; i++ {
+		var sid uuid.UUID
+		var n uint64
+		if err := binary.Read(r, binary.LittleEndian, &sid); err != nil {
+			return nil, err
+		}
+
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+
+		if n > uint64(r.Len())/16 {
+			return nil, fmt.Errorf("Invalid GTID interval count %d", n)
+		}
+
+		intervals := make([]GTIDInterval, n)
+		if err := binary.Read(r, binary.LittleEndian, intervals); err != nil {
+			return nil, err
+		}
+
+		for _, interval := range intervals {
+			event.gtidSet.AddInterval(sid, interval.From, interval.To)
+		}
+	}
+
+	return event, nil
+}
+
+// GTID_LOG_EVENT and ANONYMOUS_GTID_LOG_EVENT
+type GtidLogEvent struct {
+	header         *BinLogEventHeader
+	CommitFlag     bool
+	Sid            uuid.UUID
+	Gno            int64
+	LastCommitted  int64 // logical timestamps, used by parallel replication
+	SequenceNumber int64
+
+	// microseconds since unix epoch when the transaction was committed on the
+	// originating master and on the immediate master, 0 before 8.0.1
+	OriginalCommitTimestamp  uint64
+	ImmediateCommitTimestamp uint64
+
+	// bytes of the transaction including this event, 0 before 8.0.2
+	TransactionLength uint64
+}
+
+func (self *GtidLogEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *GtidLogEvent) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *GtidLogEvent) GetPostHeader() []string {
+	return nil
+}
+
+func (self *GtidLogEvent) GetPayload() []string {
+	return []string{
+		fmt.Sprintf("summary: %s", self.Summary()),
+		fmt.Sprintf("commit_flag: %v", self.CommitFlag),
+		fmt.Sprintf("gtid: %s", self.String()),
+		fmt.Sprintf("last_committed: %d", self.LastCommitted),
+		fmt.Sprintf("sequence_number: %d", self.SequenceNumber),
+		fmt.Sprintf("original_commit_timestamp: %s", commitTimestamp(self.OriginalCommitTimestamp)),
+		fmt.Sprintf("immediate_commit_timestamp: %s", commitTimestamp(self.ImmediateCommitTimestamp)),
+		fmt.Sprintf("transaction_length: %d", self.TransactionLength),
+	}
+}
+
+func commitTimestamp(ts uint64) string {
+	if ts == 0 {
+		return "0"
+	}
+
+	return fmt.Sprintf("%d (%v)", ts, time.Unix(0, int64(ts)*int64(time.Microsecond)).In(timeLocation))
+}
+
+// ReplicationDelay returns the time between the commit on the originating
+// master and the commit on the immediate master, 0 if unknown.
+func (self *GtidLogEvent) ReplicationDelay() time.Duration {
+	if self.OriginalCommitTimestamp == 0 || self.ImmediateCommitTimestamp < self.OriginalCommitTimestamp {
+		return 0
+	}
+
+	return time.Duration(self.ImmediateCommitTimestamp-self.OriginalCommitTimestamp) * time.Microsecond
+}
+
+// Summary describes the transaction in one line like mysqlbinlog, e.g.
+// GTID uuid:5 last_committed=3 sequence_number=4 rbr_only=yes
+// immediate_commit_ts=2019-10-02 07:06:40.000001 UTC, which is what to look at
+// for the parallel replication. The commit flag means the transaction may
+// have statement based events, i.e. rbr_only=no.
+func (self *GtidLogEvent) Summary() string {
+	rbrOnly := "yes"
+	if self.CommitFlag {
+		rbrOnly = "no"
+	}
+
+	text := fmt.Sprintf("GTID %s last_committed=%d sequence_number=%d rbr_only=%s",
+		self.String(), self.LastCommitted, self.SequenceNumber, rbrOnly)
+	if self.ImmediateCommitTimestamp != 0 {
+		text += " immediate_commit_ts=" + formatCommitTime(self.ImmediateCommitTimestamp)
+	}
+
+	if self.OriginalCommitTimestamp != 0 && self.OriginalCommitTimestamp != self.ImmediateCommitTimestamp {
+		text += " original_commit_ts=" + formatCommitTime(self.OriginalCommitTimestamp)
+	}
+
+	return text
+}
+
+func formatCommitTime(ts uint64) string {
+	return time.Unix(0, int64(ts)*int64(time.Microsecond)).In(timeLocation).Format("2006-01-02 15:04:05.000000 MST")
+}
+
+// String returns the GTID in uuid:gno form, or ANONYMOUS
+func (self *GtidLogEvent) String() string {
+	if self.header.EventType == ANONYMOUS_GTID_LOG_EVENT {
+		return "ANONYMOUS"
+	}
+
+	return fmt.Sprintf("%v:%d", self.Sid, self.Gno)
+}
+
+func newGtidLogEvent(header *BinLogEventHeader, text []byte,
+	fde *FormatDescriptionEvent) (*GtidLogEvent, error) {
+
+	end := len(text)
+	if fde.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
+		end -= BINLOG_CHECKSUM_LEN
+	}
+
+	// commit_flag + sid + gno
+	if end < 1+16+8 {
+		return nil, errors.New("Invalid GtidLogEvent len")
+	}
+
+	event := new(GtidLogEvent)
+	event.header = header
+	event.CommitFlag = text[0] != 0
+	copy(event.Sid[:], text[1:17])
+	r := bytes.NewReader(text[17:end])
+	if err := binary.Read(r, binary.LittleEndian, &event.Gno); err != nil {
 		return nil, err
 	}
 
+	// logical timestamps, since 5.7
+	var ltType uint8
+	if err := binary.Read(r, binary.LittleEndian, &ltType); err == nil && ltType == 2 {
+		if err = binary.Read(r, binary.LittleEndian, &event.LastCommitted); err != nil {
+			return nil, err
+		}
+
+		if err = binary.Read(r, binary.LittleEndian, &event.SequenceNumber); err != nil {
+			return nil, err
+		}
+	}
+
+	// commit timestamps are 7 bytes each, the highest bit of the immediate
+	// one tells whether the original one follows, since 8.0.1
+	ts := make([]byte, 8)
+	if n, _ := r.Read(ts[:7]); n == 7 {
+		event.ImmediateCommitTimestamp = binary.LittleEndian.Uint64(ts)
+		event.OriginalCommitTimestamp = event.ImmediateCommitTimestamp
+		if event.ImmediateCommitTimestamp&(1<<55) != 0 {
+			event.ImmediateCommitTimestamp &^= 1 << 55
+			if n, _ = r.Read(ts[:7]); n != 7 {
+				return nil, errors.New("Invalid GtidLogEvent original_commit_timestamp")
+			}
+
+			event.OriginalCommitTimestamp = binary.LittleEndian.Uint64(ts)
+		}
+
+		// the length of the whole transaction, since 8.0.2
+		if r.Len() > 0 {
+			length, err := readPackedInt(r)
+			if err != nil {
+				return nil, errors.New("Invalid GtidLogEvent transaction_length")
+			}
+
+			event.TransactionLength = length
+		}
+	}
+
 	return event, nil
 }
 
@@ -762,6 +1277,10 @@ type RotateEvent struct {
 	nextBinlog string
 }
 
+func (self *RotateEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
 func (self *RotateEvent) GetHeader() []string {
 	return self.header.Desc()
 }
@@ -778,15 +1297,25 @@ func (self *RotateEvent) GetPayload() []string {
 	}
 }
 
+// NextBinlog returns the name of the next binlog
+func (self *RotateEvent) NextBinlog() string {
+	return self.nextBinlog
+}
+
 func newRotateEvent(header *BinLogEventHeader, text []byte,
 	fde *FormatDescriptionEvent) (*RotateEvent, error) {
 
-	// 8 for position
-	size := len(text) - 8
+	// the post header is the 8 bytes position
+	postLen := fde.PostHeaderLen(ROTATE_EVENT, 8)
+	size := len(text) - postLen
 	if fde.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
 		size -= BINLOG_CHECKSUM_LEN
 	}
 
+	if postLen < 8 || size < 0 {
+		return nil, errors.New("Invalid RotateEvent len")
+	}
+
 	r := bytes.NewReader(text)
 	event := new(RotateEvent)
 	event.header = header
@@ -794,6 +1323,8 @@ func newRotateEvent(header *BinLogEventHeader, text []byte,
 		return nil, err
 	}
 
+	r.Seek(int64(postLen), io.SeekStart)
+
 	var filename []byte = make([]byte, size)
 	if err := binary.Read(r, binary.LittleEndian, filename); err != nil {
 		return nil, err
@@ -803,6 +1334,11 @@ func newRotateEvent(header *BinLogEventHeader, text []byte,
 	return event, nil
 }
 
+// NewBinLogEvent decodes the event body text, which excludes the common header.
+// text is only valid during the call, the parser reuses it for the next event,
+// so the decoders must copy every byte slice the event keeps instead of
+// slicing text. Event types without a built-in decoder are decoded by the
+// one registered with RegisterEventDecoder, if any.
 func NewBinLogEvent(header *BinLogEventHeader,
 	text []byte, fde *FormatDescriptionEvent) (BinLogEvent, error) {
 
@@ -814,6 +1350,7 @@ func NewBinLogEvent(header *BinLogEventHeader,
 		}
 
 		fde.ChecksumAlg = ev.ChecksumAlg
+		fde.payload = ev.payload
 		return ev, nil
 	case XID_EVENT:
 		xid, err := newXidEventPayload(header, text)
@@ -828,12 +1365,81 @@ func NewBinLogEvent(header *BinLogEventHeader,
 		return newPreviousGtidsLogEvent(header, text, fde)
 	case ROTATE_EVENT:
 		return newRotateEvent(header, text, fde)
+	case GTID_LOG_EVENT, ANONYMOUS_GTID_LOG_EVENT:
+		return newGtidLogEvent(header, text, fde)
+	case TABLE_MAP_EVENT:
+		return newTableMapEvent(header, text, fde)
+	case INTVAR_EVENT:
+		return newIntvarEvent(header, text)
+	case RAND_EVENT:
+		return newRandEvent(header, text)
+	case USER_VAR_EVENT:
+		return newUserVarEvent(header, text, fde)
+	case ROWS_QUERY_LOG_EVENT, ANNOTATE_ROWS_EVENT:
+		return newRowsQueryEvent(header, text, fde)
+	case IGNORABLE_LOG_EVENT:
+		return newIgnorableLogEvent(header, text, fde)
+	case INCIDENT_EVENT:
+		return newIncidentEvent(header, text, fde)
+	case GTID_EVENT:
+		return newMariadbGtidEvent(header, text, fde)
+	case GTID_LIST_EVENT:
+		return newMariadbGtidListEvent(header, text, fde)
+	case BINLOG_CHECKPOINT_EVENT:
+		return newBinlogCheckpointEvent(header, text, fde)
+	case START_EVENT_V3:
+		return newStartEventV3(header, text)
+	case SLAVE_EVENT:
+		return newSlaveEvent(header, text, fde)
+	case WRITE_ROWS_EVENT_V1, UPDATE_ROWS_EVENT_V1, DELETE_ROWS_EVENT_V1,
+		WRITE_ROWS_EVENT, UPDATE_ROWS_EVENT, DELETE_ROWS_EVENT:
+		return newRowsEvent(header, text, fde)
 	default:
+		if fn := lookupEventDecoder(header.EventType); fn != nil {
+			return fn(header, text, fde)
+		}
+
 		return &UnknownBinLogEvent{header}, nil
 	}
 }
 
+// DecodeEvent decodes an event from its common header and its body, which
+// includes the checksum if the FDE says so. A nil fde is the default of a
+// binlog without FORMAT_DESCRIPTION_EVENT, i.e. no checksum and the default
+// post header lengths. Like with a Parser, decoding a FORMAT_DESCRIPTION_EVENT
+// updates fde for the following events. The rows events are not decoded with
+// a table map, see Parser.
+func DecodeEvent(headerBytes, bodyBytes []byte, fde *FormatDescriptionEvent) (BinLogEvent, error) {
+	header, err := NewBinLogEventHeader(headerBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(header.EventSize) != int64(len(headerBytes)+len(bodyBytes)) {
+		return nil, fmt.Errorf("Event size %d mismatches the header and body len %d",
+			header.EventSize, len(headerBytes)+len(bodyBytes))
+	}
+
+	if fde == nil {
+		fde = new(FormatDescriptionEvent)
+	}
+
+	return NewBinLogEvent(header, bodyBytes, fde)
+}
+
+// the levels of detail of PrintEventVerbose
+const (
+	VERBOSE_HEADER  = 0 // the common header only
+	VERBOSE_PAYLOAD = 1 // the post header and the payload too, like PrintEvent
+	VERBOSE_SQL     = 2 // the SQL of the event too, e.g. the rows with the column names
+)
+
 func PrintEvent(w io.Writer, e BinLogEvent) {
+	PrintEventVerbose(w, e, VERBOSE_PAYLOAD)
+}
+
+// PrintEventVerbose prints the event with the detail of level
+func PrintEventVerbose(w io.Writer, e BinLogEvent, level int) {
 	fmt.Fprintf(w, "----------------------EVENT-------------------\n")
 	if header := e.GetHeader(); header != nil {
 		fmt.Fprintf(w, "HEADER\n")
@@ -842,6 +1448,10 @@ func PrintEvent(w io.Writer, e BinLogEvent) {
 		}
 	}
 
+	if level < VERBOSE_PAYLOAD {
+		return
+	}
+
 	if postHeader := e.GetPostHeader(); postHeader != nil {
 		fmt.Fprintf(w, "POST_HEADER\n")
 		for _, val := range postHeader {
@@ -855,4 +1465,41 @@ func PrintEvent(w io.Writer, e BinLogEvent) {
 			fmt.Fprintf(w, "	%s\n", val)
 		}
 	}
+
+	if level < VERBOSE_SQL {
+		return
+	}
+
+	if stmts := eventSQL(e); stmts != nil {
+		fmt.Fprintf(w, "SQL\n")
+		for _, stmt := range stmts {
+			fmt.Fprintf(w, "	%s\n", strings.Replace(stmt, "\n", "\n	", -1))
+		}
+	}
+}
+
+// eventSQL returns the statements of the event, redacted if the parser is
+// told to, or nil if it has none. The error of a rows event which can not be
+// converted is returned as a comment.
+func eventSQL(e BinLogEvent) []string {
+	switch event := e.(type) {
+	case *QueryEvent:
+		return []string{event.query()}
+	case *RowsQueryEvent:
+		return []string{event.comment()}
+	case *RowsEvent:
+		stmts, err := event.SQL()
+		if err != nil {
+			return []string{"-- " + err.Error()}
+		}
+		return stmts
+	case *IntvarEvent:
+		return []string{event.SQL()}
+	case *RandEvent:
+		return []string{event.SQL()}
+	case *UserVarEvent:
+		return []string{event.SQL()}
+	default:
+		return nil
+	}
 }
@@ -0,0 +1,306 @@
+//
+// events_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the GTID of a transaction committed at 2019-10-02 07:06:40.123456 UTC on
+// the immediate master and at 07:06:40 on the original master
+const gtid80 = `
+	# GTID_LOG_EVENT at 123
+	80 4c 94 5d 21 01 00 00 00 4f 00 00 00 ca 00 00 00 00 00 01 3e 11 fa 47
+	71 ca 11 e1 9e 33 c8 0a a9 42 95 62 09 00 00 00 00 00 00 00 02 00 00 00
+	00 00 00 00 00 01 00 00 00 00 00 00 00 40 02 4e 1c e8 93 85 00 20 4c 1c
+	e8 93 05 71 b7 98 4a
+`
+
+func TestGtidSummaryTimeLocation(t *testing.T) {
+	defer SetTimeLocation(TimeLocation())
+	gtid := readEvents(t, fde80+gtid80)[1].(*GtidLogEvent)
+	tests := []struct {
+		loc  *time.Location
+		want string
+	}{
+		{time.UTC, "GTID 3e11fa47-71ca-11e1-9e33-c80aa9429562:9 last_committed=0 sequence_number=1 rbr_only=no " +
+			"immediate_commit_ts=2019-10-02 07:06:40.123456 UTC original_commit_ts=2019-10-02 07:06:40.000000 UTC"},
+		{time.FixedZone("CST", 8*3600), "GTID 3e11fa47-71ca-11e1-9e33-c80aa9429562:9 last_committed=0 sequence_number=1 rbr_only=no " +
+			"immediate_commit_ts=2019-10-02 15:06:40.123456 CST original_commit_ts=2019-10-02 15:06:40.000000 CST"},
+	}
+
+	for _, test := range tests {
+		SetTimeLocation(test.loc)
+		if summary := gtid.Summary(); summary != test.want {
+			t.Errorf("%v: got %s, want %s", test.loc, summary, test.want)
+		}
+	}
+}
+
+// the queries of MySQL 8.0 and MariaDB with a newer status var followed by
+// Q_CHARSET_CODE, and the last one with the unknown status var 0x7f
+const queryStatusVars = `
+	# QUERY_EVENT at 123
+	80 4c 94 5d 02 01 00 00 00 47 00 00 00 c2 00 00 00 00 00 05 00 00 00 00
+	00 00 00 04 00 00 09 00 10 01 04 21 00 21 00 ff 00 74 65 73 74 00 63 72
+	65 61 74 65 20 74 61 62 6c 65 20 74 28 61 20 69 6e 74 29 d1 e2 db 56
+	# QUERY_EVENT at 194
+	80 4c 94 5d 02 01 00 00 00 4e 00 00 00 10 01 00 00 00 00 05 00 00 00 00
+	00 00 00 04 00 00 10 00 11 07 00 00 00 00 00 00 00 04 21 00 21 00 ff 00
+	74 65 73 74 00 63 72 65 61 74 65 20 74 61 62 6c 65 20 74 28 61 20 69 6e
+	74 29 ae 28 43 0f
+	# QUERY_EVENT at 272
+	80 4c 94 5d 02 01 00 00 00 48 00 00 00 58 01 00 00 00 00 05 00 00 00 00
+	00 00 00 04 00 00 0a 00 12 ff 00 04 21 00 21 00 ff 00 74 65 73 74 00 63
+	72 65 61 74 65 20 74 61 62 6c 65 20 74 28 61 20 69 6e 74 29 6e 15 b7 75
+	# QUERY_EVENT at 344
+	80 4c 94 5d 02 01 00 00 00 47 00 00 00 9f 01 00 00 00 00 05 00 00 00 00
+	00 00 00 04 00 00 09 00 13 01 04 21 00 21 00 ff 00 74 65 73 74 00 63 72
+	65 61 74 65 20 74 61 62 6c 65 20 74 28 61 20 69 6e 74 29 b7 d8 fe da
+	# QUERY_EVENT at 415
+	80 4c 94 5d 02 01 00 00 00 47 00 00 00 e6 01 00 00 00 00 05 00 00 00 00
+	00 00 00 04 00 00 09 00 14 00 04 21 00 21 00 ff 00 74 65 73 74 00 63 72
+	65 61 74 65 20 74 61 62 6c 65 20 74 28 61 20 69 6e 74 29 1c c0 38 b6
+	# QUERY_EVENT at 486
+	80 4c 94 5d 02 01 00 00 00 49 00 00 00 2f 02 00 00 00 00 05 00 00 00 00
+	00 00 00 04 00 00 0b 00 80 40 e2 01 04 21 00 21 00 ff 00 74 65 73 74 00
+	63 72 65 61 74 65 20 74 61 62 6c 65 20 74 28 61 20 69 6e 74 29 8b 33 a3
+	51
+	# QUERY_EVENT at 559
+	80 4c 94 5d 02 01 00 00 00 4e 00 00 00 7d 02 00 00 00 00 05 00 00 00 00
+	00 00 00 04 00 00 10 00 81 40 e2 01 00 00 00 00 00 04 21 00 21 00 ff 00
+	74 65 73 74 00 63 72 65 61 74 65 20 74 61 62 6c 65 20 74 28 61 20 69 6e
+	74 29 f2 05 4e 0b
+	# QUERY_EVENT at 637
+	80 4c 94 5d 02 01 00 00 00 49 00 00 00 c6 02 00 00 00 00 05 00 00 00 00
+	00 00 00 04 00 00 0b 00 7f 01 02 03 04 21 00 21 00 ff 00 74 65 73 74 00
+	63 72 65 61 74 65 20 74 61 62 6c 65 20 74 28 61 20 69 6e 74 29 18 05 b1
+	81
+`
+
+func TestQueryStatusVars(t *testing.T) {
+	charset := []uint16{33, 33, 255}
+	tests := []map[QStatusKey]Any{
+		{Q_EXPLICIT_DEFAULTS_FOR_TIMESTAMP: uint8(1), Q_CHARSET_CODE: charset},
+		{Q_DDL_LOGGED_WITH_XID: uint64(7), Q_CHARSET_CODE: charset},
+		{Q_DEFAULT_COLLATION_FOR_UTF8MB4: uint16(255), Q_CHARSET_CODE: charset},
+		{Q_SQL_REQUIRE_PRIMARY_KEY: uint8(1), Q_CHARSET_CODE: charset},
+		{Q_DEFAULT_TABLE_ENCRYPTION: uint8(0), Q_CHARSET_CODE: charset},
+		{Q_HRNOW: []byte{0x40, 0xe2, 0x01}, Q_CHARSET_CODE: charset},
+		{Q_XID: uint64(123456), Q_CHARSET_CODE: charset},
+		// the rest of the status vars after an unknown one is kept as is
+		{QStatusKey(0x7f): []byte{1, 2, 3, 4, 0x21, 0, 0x21, 0, 0xff, 0}},
+	}
+
+	events := readEvents(t, fde80+queryStatusVars)[1:]
+	for i, want := range tests {
+		event := events[i].(*QueryEvent)
+		if got := event.payload.StatusVars; !reflect.DeepEqual(got, want) {
+			t.Errorf("event %d: got status vars %v, want %v", i, got, want)
+		}
+
+		if event.Schema() != "test" || event.Query() != "create table t(a int)" {
+			t.Errorf("event %d: got schema %q and query %q", i, event.Schema(), event.Query())
+		}
+	}
+}
+
+type testLogger struct {
+	warnings []string
+}
+
+func (self *testLogger) Debugf(format string, args ...interface{}) {}
+
+func (self *testLogger) Warnf(format string, args ...interface{}) {
+	self.warnings = append(self.warnings, fmt.Sprintf(format, args...))
+}
+
+func TestQueryUnknownStatusVarWarning(t *testing.T) {
+	parser, err := NewParser(bytes.NewReader(unhex(t, fde80+queryStatusVars)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := new(testLogger)
+	parser.SetLogger(logger)
+	for {
+		if _, err = parser.ReadEvent(); err != nil {
+			break
+		}
+	}
+
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+
+	want := []string{"Unknown status var UNKNOWN(0x7f) of the QueryEvent at 637, the status vars after it are not decoded"}
+	if !reflect.DeepEqual(logger.warnings, want) {
+		t.Errorf("got warnings %q, want %q", logger.warnings, want)
+	}
+}
+
+func TestFormatDescriptionChecksum(t *testing.T) {
+	tests := []struct {
+		name    string
+		dump    string
+		version string
+		alg     BinlogChecksumAlg
+	}{
+		{"5.5 without checksum", `
+			80 4c 94 5d 0f 01 00 00 00 72 00 00 00 76 00 00 00 00 00 04 00 35 2e 35
+			2e 36 32 2d 6c 6f 67 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00 13 38 0d 00 08 00 12 00 04 04 04 04 12 00 00 5f 00 04 1a 08 00
+			00 00 08 08 08 02 00 00 00 0a 0a 0a 2a 2a 00 12 34 00`,
+			"5.5.62-log", BINLOG_CHECKSUM_ALG_OFF},
+		{"5.7 with CRC32", fde57[strings.Index(fde57, "80 4c"):], "5.7.26-log", BINLOG_CHECKSUM_ALG_CRC32},
+		{"5.7 with binlog_checksum=NONE", `
+			80 4c 94 5d 0f 01 00 00 00 77 00 00 00 7b 00 00 00 00 00 04 00 35 2e 37
+			2e 32 36 2d 6c 6f 67 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00 13 38 0d 00 08 00 12 00 04 04 04 04 12 00 00 5f 00 04 1a 08 00
+			00 00 08 08 08 02 00 00 00 0a 0a 0a 2a 2a 00 12 34 00 00 49 d5 6b fe`,
+			"5.7.26-log", BINLOG_CHECKSUM_ALG_OFF},
+		// the length of the FDE decides over the version
+		{"5.6.0 beta with checksum", `
+			80 4c 94 5d 0f 01 00 00 00 77 00 00 00 7b 00 00 00 00 00 04 00 35 2e 36
+			2e 30 2d 6d 34 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00 13 38 0d 00 08 00 12 00 04 04 04 04 12 00 00 5f 00 04 1a 08 00
+			00 00 08 08 08 02 00 00 00 0a 0a 0a 2a 2a 00 12 34 00 01 0f fc f6 3d`,
+			"5.6.0-m4", BINLOG_CHECKSUM_ALG_CRC32},
+		{"5.6.1 without checksum", `
+			80 4c 94 5d 0f 01 00 00 00 72 00 00 00 76 00 00 00 00 00 04 00 35 2e 36
+			2e 31 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00 13 38 0d 00 08 00 12 00 04 04 04 04 12 00 00 5f 00 04 1a 08 00
+			00 00 08 08 08 02 00 00 00 0a 0a 0a 2a 2a 00 12 34 00`,
+			"5.6.1", BINLOG_CHECKSUM_ALG_OFF},
+		{"unparsable version with checksum", `
+			80 4c 94 5d 0f 01 00 00 00 77 00 00 00 7b 00 00 00 00 00 04 00 78 2d 79
+			00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00 13 38 0d 00 08 00 12 00 04 04 04 04 12 00 00 5f 00 04 1a 08 00
+			00 00 08 08 08 02 00 00 00 0a 0a 0a 2a 2a 00 12 34 00 01 fa a6 56 01`,
+			"x-y", BINLOG_CHECKSUM_ALG_CRC32},
+	}
+
+	for _, test := range tests {
+		raw := unhex(t, test.dump)
+		event, err := DecodeEvent(raw[:BINLOG_EVENT_HEADER_LEN], raw[BINLOG_EVENT_HEADER_LEN:], nil)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		fde := event.(*FormatDescriptionEvent)
+		if fde.ChecksumAlg != test.alg || fde.payload.MySQLServerVersion != test.version {
+			t.Errorf("%s: got %s with %v, want %s with %v", test.name, fde.payload.MySQLServerVersion,
+				fde.ChecksumAlg, test.version, test.alg)
+		}
+
+		// the post header lengths of the event types up to XA_PREPARE_LOG_EVENT
+		if len(fde.payload.EventTypeHeaderLength) != 38 {
+			t.Errorf("%s: got %d post header lengths, want 38", test.name, len(fde.payload.EventTypeHeaderLength))
+		}
+	}
+}
+
+func TestQueryStatusVarsLengthInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+		err  string
+	}{
+		{"status_vars_length 255", `
+			80 4c 94 5d 02 01 00 00 00 53 00 00 00 1d 01 00 00 00 00 05 00 00 00 00
+			00 00 00 04 00 00 ff 00 00 00 00 00 00 01 00 00 00 40 00 00 00 00 04 21
+			00 21 00 08 00 74 65 73 74 00 63 72 65 61 74 65 20 74 61 62 6c 65 20 78
+			28 61 20 69 6e 74 29 b8 88 da 7a`,
+			"Invalid QueryEvent status_vars_length 255 and schema_length 4 for payload len 47"},
+		{"schema_length 255", `
+			80 4c 94 5d 02 01 00 00 00 53 00 00 00 1d 01 00 00 00 00 05 00 00 00 00
+			00 00 00 ff 00 00 15 00 00 00 00 00 00 01 00 00 00 40 00 00 00 00 04 21
+			00 21 00 08 00 74 65 73 74 00 63 72 65 61 74 65 20 74 61 62 6c 65 20 78
+			28 61 20 69 6e 74 29 dc 82 55 f8`,
+			"Invalid QueryEvent status_vars_length 21 and schema_length 255 for payload len 47"},
+		// the Q_CHARSET_CODE is cut by the status_vars_length, it is not read
+		// from the schema
+		{"status_vars_length 20", `
+			80 4c 94 5d 02 01 00 00 00 53 00 00 00 1d 01 00 00 00 00 05 00 00 00 00
+			00 00 00 04 00 00 14 00 00 00 00 00 00 01 00 00 00 40 00 00 00 00 04 21
+			00 21 00 08 00 74 65 73 74 00 63 72 65 61 74 65 20 74 61 62 6c 65 20 78
+			28 61 20 69 6e 74 29 30 a1 1e 6a`,
+			"unexpected EOF"},
+	}
+
+	fde := readEvents(t, fde80)[0].(*FormatDescriptionEvent)
+	for _, test := range tests {
+		raw := unhex(t, test.dump)
+		_, err := DecodeEvent(raw[:BINLOG_EVENT_HEADER_LEN], raw[BINLOG_EVENT_HEADER_LEN:], fde)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: got %v, want %s", test.name, err, test.err)
+		}
+	}
+}
+
+func TestFormatDescriptionShort(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+		err  bool
+	}{
+		{"no body", `
+			80 4c 94 5d 0f 01 00 00 00 13 00 00 00 7b 00 00 00 01 00`, true},
+		{"cut in the server version", `
+			80 4c 94 5d 0f 01 00 00 00 4b 00 00 00 7b 00 00 00 01 00 04 00 35 2e 37
+			2e 32 36 2d 6c 6f 67 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00`, true},
+		// without the post header length of the FDE itself, the 3 bytes after
+		// the common header length are the post header lengths and there is
+		// no checksum
+		{"3 post header lengths", `
+			80 4c 94 5d 0f 01 00 00 00 4f 00 00 00 7b 00 00 00 01 00 04 00 35 2e 37
+			2e 32 36 2d 6c 6f 67 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+			00 00 00 13 38 0d 00`, false},
+	}
+
+	for _, test := range tests {
+		raw := unhex(t, test.dump)
+		event, err := DecodeEvent(raw[:BINLOG_EVENT_HEADER_LEN], raw[BINLOG_EVENT_HEADER_LEN:], nil)
+		if !test.err {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			} else if fde := event.(*FormatDescriptionEvent); len(fde.payload.EventTypeHeaderLength) != 3 ||
+				fde.ChecksumAlg != BINLOG_CHECKSUM_ALG_OFF {
+				t.Errorf("%s: got %v with %v", test.name, fde.payload.EventTypeHeaderLength, fde.ChecksumAlg)
+			}
+
+			continue
+		}
+
+		if err == nil || err.Error() != "Invalid FormatDescriptionEventPayload len" {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+
+		parser, err := NewParser(bytes.NewReader(append(append([]byte(nil), binlogMagic...), raw...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = parser.ReadEvent(); err == nil || err == io.EOF {
+			t.Errorf("%s: ReadEvent got %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+//
+// fixture_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Helpers to read the binlogs of the tests, which are written as hex dumps
+//
+
+package binlog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+// unhex decodes a hex dump, the lines starting with # are comments
+func unhex(t testing.TB, dump string) []byte {
+	t.Helper()
+	var sb strings.Builder
+	for _, line := range strings.Split(dump, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			sb.WriteString(strings.Replace(line, " ", "", -1))
+		}
+	}
+
+	data, err := hex.DecodeString(sb.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// readEvents reads all the events of the binlog in a hex dump
+func readEvents(t testing.TB, dump string) []BinLogEvent {
+	t.Helper()
+	return parseEvents(t, unhex(t, dump))
+}
+
+// parseEvents reads all the events of a binlog
+func parseEvents(t testing.TB, data []byte) []BinLogEvent {
+	t.Helper()
+	parser, err := NewParser(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []BinLogEvent
+	for {
+		event, err := parser.ReadEvent()
+		if err == io.EOF {
+			return events
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		events = append(events, event)
+	}
+}
+
+// the magic and the FORMAT_DESCRIPTION_EVENT of a MySQL 5.7.26 binlog with
+// binlog_checksum=CRC32, which start the binlogs of the tests
+const fde57 = `
+	# magic
+	fe 62 69 6e
+	# FORMAT_DESCRIPTION_EVENT at 4
+	80 4c 94 5d 0f 01 00 00 00 77 00 00 00 7b 00 00 00 01 00 04 00 35 2e 37
+	2e 32 36 2d 6c 6f 67 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+	00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+	00 00 00 13 38 0d 00 08 00 12 00 04 04 04 04 12 00 00 5f 00 04 1a 08 00
+	00 00 08 08 08 02 00 00 00 0a 0a 0a 2a 2a 00 12 34 00 01 88 70 a9 e3
+`
+
+// the magic and the FORMAT_DESCRIPTION_EVENT of a MySQL 8.0.18 binlog with
+// binlog_checksum=CRC32
+const fde80 = `
+	# magic
+	fe 62 69 6e
+	# FORMAT_DESCRIPTION_EVENT at 4
+	80 4c 94 5d 0f 01 00 00 00 77 00 00 00 7b 00 00 00 01 00 04 00 38 2e 30
+	2e 31 38 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+	00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+	00 00 00 13 38 0d 00 08 00 12 00 04 04 04 04 12 00 00 5f 00 04 1a 08 00
+	00 00 08 08 08 02 00 00 00 0a 0a 0a 2a 2a 00 12 34 00 01 88 dc c4 05
+`
@@ -0,0 +1,207 @@
+//
+// geometry.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// GEOMETRY column values, which are stored as a 4 bytes SRID followed by WKB
+//
+
+package binlog
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WKB geometry types
+const (
+	WKB_POINT              = 1
+	WKB_LINESTRING         = 2
+	WKB_POLYGON            = 3
+	WKB_MULTIPOINT         = 4
+	WKB_MULTILINESTRING    = 5
+	WKB_MULTIPOLYGON       = 6
+	WKB_GEOMETRYCOLLECTION = 7
+)
+
+// Geometry is a decoded GEOMETRY value, WKT is empty if the WKB is not
+// supported
+type Geometry struct {
+	SRID uint32 `json:"srid"`
+	WKT  string `json:"wkt,omitempty"`
+	WKB  []byte `json:"wkb"`
+}
+
+func (self *Geometry) String() string {
+	if self.WKT != "" {
+		return self.WKT
+	}
+
+	return "0x" + hex.EncodeToString(self.WKB)
+}
+
+// SQL returns the value as a SQL expression
+func (self *Geometry) SQL() string {
+	if self.WKT != "" {
+		return fmt.Sprintf("ST_GeomFromText('%s', %d)", self.WKT, self.SRID)
+	}
+
+	return fmt.Sprintf("ST_GeomFromWKB(0x%s, %d)", hex.EncodeToString(self.WKB), self.SRID)
+}
+
+type wkbReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+var errInvalidWKB = errors.New("Invalid WKB")
+
+func (self *wkbReader) uint32() (uint32, error) {
+	if len(self.data) < 4 {
+		return 0, errInvalidWKB
+	}
+
+	val := self.order.Uint32(self.data)
+	self.data = self.data[4:]
+	return val, nil
+}
+
+func (self *wkbReader) points(n uint32) (string, error) {
+	if uint64(len(self.data)) < uint64(n)*16 {
+		return "", errInvalidWKB
+	}
+
+	points := make([]string, n)
+	for i := range points {
+		x := math.Float64frombits(self.order.Uint64(self.data))
+		y := math.Float64frombits(self.order.Uint64(self.data[8:]))
+		self.data = self.data[16:]
+		points[i] = strconv.FormatFloat(x, 'g', -1, 64) + " " + strconv.FormatFloat(y, 'g', -1, 64)
+	}
+
+	return strings.Join(points, ","), nil
+}
+
+func (self *wkbReader) lineString() (string, error) {
+	n, err := self.uint32()
+	if err != nil {
+		return "", err
+	}
+
+	points, err := self.points(n)
+	return "(" + points + ")", err
+}
+
+func (self *wkbReader) polygon() (string, error) {
+	n, err := self.uint32()
+	if err != nil {
+		return "", err
+	}
+
+	if uint64(n) > uint64(len(self.data)) {
+		return "", errInvalidWKB
+	}
+
+	rings := make([]string, n)
+	for i := range rings {
+		if rings[i], err = self.lineString(); err != nil {
+			return "", err
+		}
+	}
+
+	return "(" + strings.Join(rings, ",") + ")", nil
+}
+
+// geometry reads a geometry with its byte order and type, the WKT of the
+// elements of a multi geometry omits the type name
+func (self *wkbReader) geometry(withName bool) (string, error) {
+	if len(self.data) < 1 {
+		return "", errInvalidWKB
+	}
+
+	switch self.data[0] {
+	case 0:
+		self.order = binary.BigEndian
+	case 1:
+		self.order = binary.LittleEndian
+	default:
+		return "", errInvalidWKB
+	}
+
+	self.data = self.data[1:]
+	t, err := self.uint32()
+	if err != nil {
+		return "", err
+	}
+
+	var name, body string
+	switch t {
+	case WKB_POINT:
+		name = "POINT"
+		body, err = self.points(1)
+		body = "(" + body + ")"
+	case WKB_LINESTRING:
+		name = "LINESTRING"
+		body, err = self.lineString()
+	case WKB_POLYGON:
+		name = "POLYGON"
+		body, err = self.polygon()
+	case WKB_MULTIPOINT, WKB_MULTILINESTRING, WKB_MULTIPOLYGON, WKB_GEOMETRYCOLLECTION:
+		name = map[uint32]string{
+			WKB_MULTIPOINT:         "MULTIPOINT",
+			WKB_MULTILINESTRING:    "MULTILINESTRING",
+			WKB_MULTIPOLYGON:       "MULTIPOLYGON",
+			WKB_GEOMETRYCOLLECTION: "GEOMETRYCOLLECTION",
+		}[t]
+
+		var n uint32
+		if n, err = self.uint32(); err != nil {
+			return "", err
+		}
+
+		if uint64(n) > uint64(len(self.data)) {
+			return "", errInvalidWKB
+		}
+
+		elems := make([]string, n)
+		for i := range elems {
+			if elems[i], err = self.geometry(t == WKB_GEOMETRYCOLLECTION); err != nil {
+				return "", err
+			}
+		}
+
+		body = "(" + strings.Join(elems, ",") + ")"
+	default:
+		return "", fmt.Errorf("Unsupported WKB type %d", t)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if withName {
+		return name + body, nil
+	}
+
+	return body, nil
+}
+
+// decodeGeometry decodes the value of a GEOMETRY column, the WKB is kept
+// as is if it can not be converted to WKT
+func decodeGeometry(data []byte) (*Geometry, error) {
+	if len(data) < 4 {
+		return nil, errors.New("Invalid GEOMETRY len")
+	}
+
+	geom := &Geometry{SRID: binary.LittleEndian.Uint32(data), WKB: data[4:]}
+	r := &wkbReader{data: geom.WKB}
+	if wkt, err := r.geometry(true); err == nil && len(r.data) == 0 {
+		geom.WKT = wkt
+	}
+
+	return geom, nil
+}
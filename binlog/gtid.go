@@ -0,0 +1,263 @@
+//
+// gtid.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GTIDInterval is the transaction numbers From to To-1 of a server, To is
+// exclusive like in the binlog
+type GTIDInterval struct {
+	From uint64
+	To   uint64
+}
+
+// GTIDSet is a set of GTIDs, the intervals of each sid are sorted and
+// neither overlap nor adjoin. It replaces the struct of one sid and interval
+// of the same name, GTIDSet{Gtid: sid, From: from, To: to} is now built with
+// AddInterval(sid, from, to).
+type GTIDSet map[uuid.UUID][]GTIDInterval
+
+// AddInterval adds the transaction numbers from to to-1 of sid
+func (self GTIDSet) AddInterval(sid uuid.UUID, from, to uint64) {
+	if from >= to {
+		return
+	}
+
+	self[sid] = mergeIntervals(append(self[sid], GTIDInterval{from, to}))
+}
+
+// Add adds the transaction gno of sid
+func (self GTIDSet) Add(sid uuid.UUID, gno uint64) {
+	self.AddInterval(sid, gno, gno+1)
+}
+
+// Merge adds all the GTIDs of other to the set
+func (self GTIDSet) Merge(other GTIDSet) {
+	for sid, intervals := range other {
+		merged := append([]GTIDInterval(nil), self[sid]...)
+		self[sid] = mergeIntervals(append(merged, intervals...))
+	}
+}
+
+// Clone returns a copy of the set
+func (self GTIDSet) Clone() GTIDSet {
+	set := make(GTIDSet, len(self))
+	for sid, intervals := range self {
+		set[sid] = append([]GTIDInterval(nil), intervals...)
+	}
+
+	return set
+}
+
+// Union returns a new set of the GTIDs in the set or in other
+func (self GTIDSet) Union(other GTIDSet) GTIDSet {
+	set := self.Clone()
+	set.Merge(other)
+	return set
+}
+
+// Subtract returns a new set of the GTIDs in the set but not in other, e.g.
+// the transactions of a binlog which a replica has not executed yet
+func (self GTIDSet) Subtract(other GTIDSet) GTIDSet {
+	set := make(GTIDSet)
+	for sid, intervals := range self {
+		var rest []GTIDInterval
+		for _, interval := range intervals {
+			from := interval.From
+			for _, sub := range other[sid] {
+				if sub.To <= from {
+					continue
+				}
+
+				if sub.From >= interval.To {
+					break
+				}
+
+				if sub.From > from {
+					rest = append(rest, GTIDInterval{from, sub.From})
+				}
+
+				from = sub.To
+			}
+
+			if from < interval.To {
+				rest = append(rest, GTIDInterval{from, interval.To})
+			}
+		}
+
+		if len(rest) != 0 {
+			set[sid] = rest
+		}
+	}
+
+	return set
+}
+
+// Contains reports whether the transaction gno of sid is in the set
+func (self GTIDSet) Contains(sid uuid.UUID, gno uint64) bool {
+	intervals := self[sid]
+	i := sort.Search(len(intervals), func(i int) bool { return intervals[i].To > gno })
+	return i < len(intervals) && intervals[i].From <= gno
+}
+
+// ParseGtidSet parses a GTID set in the form of gtid_executed, e.g.
+// 3e11fa47-71ca-11e1-9e33-c80aa9429562:1-100:200-250,... The whitespace
+// around the elements is ignored, and an empty string is the empty set.
+func ParseGtidSet(text string) (GTIDSet, error) {
+	set := make(GTIDSet)
+	if strings.TrimSpace(text) == "" {
+		return set, nil
+	}
+
+	for _, elem := range strings.Split(text, ",") {
+		parts := strings.Split(strings.TrimSpace(elem), ":")
+		sid, err := uuid.Parse(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid GTID set %q: %v", elem, err)
+		}
+
+		if len(parts) == 1 {
+			return nil, fmt.Errorf("Invalid GTID set %q: no interval", elem)
+		}
+
+		for _, part := range parts[1:] {
+			from, to, err := parseGtidInterval(strings.TrimSpace(part))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid GTID set %q: %v", elem, err)
+			}
+
+			set.AddInterval(sid, from, to)
+		}
+	}
+
+	return set, nil
+}
+
+// parseGtidInterval parses N or N-M into the interval from N to M+1
+func parseGtidInterval(text string) (uint64, uint64, error) {
+	bounds := strings.SplitN(text, "-", 2)
+	from, err := strconv.ParseUint(bounds[0], 10, 64)
+	if err != nil || from == 0 {
+		return 0, 0, fmt.Errorf("invalid interval %q", text)
+	}
+
+	to := from
+	if len(bounds) == 2 {
+		if to, err = strconv.ParseUint(bounds[1], 10, 64); err != nil || to < from {
+			return 0, 0, fmt.Errorf("invalid interval %q", text)
+		}
+	}
+
+	return from, to + 1, nil
+}
+
+func mergeIntervals(intervals []GTIDInterval) []GTIDInterval {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].From < intervals[j].From })
+	var merged []GTIDInterval
+	for _, interval := range intervals {
+		if interval.From >= interval.To {
+			continue
+		}
+
+		last := len(merged) - 1
+		if last >= 0 && interval.From <= merged[last].To {
+			if interval.To > merged[last].To {
+				merged[last].To = interval.To
+			}
+		} else {
+			merged = append(merged, interval)
+		}
+	}
+
+	return merged
+}
+
+// String returns the set in the canonical form of gtid_executed, e.g.
+// 3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7, the sids are sorted
+func (self GTIDSet) String() string {
+	sids := make([]string, 0, len(self))
+	for sid, intervals := range self {
+		if len(intervals) == 0 {
+			continue
+		}
+
+		text := sid.String()
+		for _, interval := range intervals {
+			if interval.To-1 == interval.From {
+				text += fmt.Sprintf(":%d", interval.From)
+			} else {
+				text += fmt.Sprintf(":%d-%d", interval.From, interval.To-1)
+			}
+		}
+
+		sids = append(sids, text)
+	}
+
+	sort.Strings(sids)
+	return strings.Join(sids, ",")
+}
+
+func (self GTIDSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(self.String())
+}
+
+// GtidSet reads the rest of the binlog and returns the GTIDs of its
+// PREVIOUS_GTIDS_LOG_EVENT, i.e. the ones inherited from the binlogs before
+// it, and the GTIDs of its own transactions. Only the FDE, PREVIOUS_GTIDS and
+// GTID events are decoded, the other events are skipped by their headers, or
+// the whole transaction with a seek if the GTID event has transaction_length.
+func (self *Parser) GtidSet() (previous GTIDSet, contained GTIDSet, err error) {
+	if self.fde == nil {
+		self.fde = new(FormatDescriptionEvent)
+	}
+
+	previous, contained = make(GTIDSet), make(GTIDSet)
+	for {
+		var header *BinLogEventHeader
+		if header, err = self.readEventHeader(); err == io.EOF {
+			return previous, contained, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		switch header.EventType {
+		case FORMAT_DESCRIPTION_EVENT, PREVIOUS_GTIDS_LOG_EVENT, GTID_LOG_EVENT:
+		default:
+			if err = self.skipBody(header); err != nil {
+				return nil, nil, err
+			}
+
+			continue
+		}
+
+		var event BinLogEvent
+		event, err = self.readEvent(header)
+		if event, err = self.countEvent(header, event, err); err != nil {
+			return nil, nil, err
+		}
+
+		switch e := event.(type) {
+		case *PreviousGtidsLogEvent:
+			previous.Merge(e.GTIDSet())
+		case *GtidLogEvent:
+			contained.Add(e.Sid, uint64(e.Gno))
+			if seeker, ok := self.reader.(io.Seeker); ok && self.txnEnd > self.pos {
+				self.stmtEnd = true
+				if err = self.seek(seeker, self.txnEnd); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+}
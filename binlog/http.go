@@ -0,0 +1,176 @@
+//
+// http.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Serve parsed binlog events over HTTP
+//
+
+package binlog
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	HTTP_DEFAULT_EVENT_COUNT = 100
+	HTTP_MAX_EVENT_COUNT     = 10000
+)
+
+type binlogFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+type eventPage struct {
+	File   string       `json:"file"`
+	Start  int          `json:"start"`
+	Next   int          `json:"next,omitempty"` // 0 when the end of the binlog is reached
+	Events []*EventJSON `json:"events"`
+}
+
+type handler struct {
+	dir string
+}
+
+// Handler returns a http.Handler which serves the binlogs under dir:
+//
+//	GET /                          list the binlog files as JSON
+//	GET /<file>?start=N&count=M    M parsed events from the Nth event as JSON
+func Handler(dir string) http.Handler {
+	return &handler{dir}
+}
+
+func (self *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if name == "" {
+		self.serveList(w)
+		return
+	}
+
+	if strings.ContainsAny(name, "/\\") || name == "." || name == ".." {
+		http.Error(w, "Invalid binlog name", http.StatusBadRequest)
+		return
+	}
+
+	start, err := queryInt(r, "start", 0)
+	if err != nil || start < 0 {
+		http.Error(w, "Invalid start", http.StatusBadRequest)
+		return
+	}
+
+	count, err := queryInt(r, "count", HTTP_DEFAULT_EVENT_COUNT)
+	if err != nil || count <= 0 || count > HTTP_MAX_EVENT_COUNT {
+		http.Error(w, "Invalid count", http.StatusBadRequest)
+		return
+	}
+
+	self.serveEvents(w, name, start, count)
+}
+
+func queryInt(r *http.Request, key string, def int) (int, error) {
+	val := r.URL.Query().Get(key)
+	if val == "" {
+		return def, nil
+	}
+
+	return strconv.Atoi(val)
+}
+
+func isBinlogFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	if _, err = io.ReadFull(file, magic); err != nil {
+		return false
+	}
+
+	return magic[0] == 0xfe && magic[1] == 'b' && magic[2] == 'i' && magic[3] == 'n'
+}
+
+func (self *handler) serveList(w http.ResponseWriter) {
+	infos, err := ioutil.ReadDir(self.dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	files := []binlogFile{}
+	for _, info := range infos {
+		if info.Mode().IsRegular() && isBinlogFile(filepath.Join(self.dir, info.Name())) {
+			files = append(files, binlogFile{info.Name(), info.Size()})
+		}
+	}
+
+	writeJSON(w, files)
+}
+
+func (self *handler) serveEvents(w http.ResponseWriter, name string, start int, count int) {
+	file, err := os.Open(filepath.Join(self.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "Binlog not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	defer file.Close()
+
+	parser, err := NewParser(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	page := &eventPage{File: name, Start: start, Events: []*EventJSON{}}
+	for i := 0; i < start; i++ {
+		if err = parser.SkipEvent(); err != nil {
+			if err == io.EOF {
+				writeJSON(w, page)
+			} else {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			}
+			return
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		event, err := parser.ReadEvent()
+		if err != nil {
+			if err == io.EOF {
+				writeJSON(w, page)
+			} else {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			}
+			return
+		}
+
+		page.Events = append(page.Events, NewEventJSON(event))
+	}
+
+	page.Next = start + count
+	writeJSON(w, page)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,86 @@
+//
+// incident.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// INCIDENT_EVENT, written when the binlog misses the changes of the master,
+// e.g. a non-transactional statement failed to be logged
+//
+
+package binlog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// types of INCIDENT_EVENT
+const (
+	INCIDENT_NONE        = 0
+	INCIDENT_LOST_EVENTS = 1 // the replicas stop with an error
+)
+
+const INCIDENT_HEADER_LEN = 2
+
+type IncidentEvent struct {
+	header   *BinLogEventHeader
+	Incident uint16
+	Message  string
+}
+
+func (self *IncidentEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *IncidentEvent) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *IncidentEvent) GetPostHeader() []string {
+	return []string{fmt.Sprintf("incident: %d (%s)", self.Incident, self.Name())}
+}
+
+func (self *IncidentEvent) GetPayload() []string {
+	return []string{fmt.Sprintf("message: %s", self.Message)}
+}
+
+// Name returns the name of the incident type
+func (self *IncidentEvent) Name() string {
+	switch self.Incident {
+	case INCIDENT_NONE:
+		return "NONE"
+	case INCIDENT_LOST_EVENTS:
+		return "LOST_EVENTS"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func newIncidentEvent(header *BinLogEventHeader, text []byte, fde *FormatDescriptionEvent) (*IncidentEvent, error) {
+	if fde.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
+		if len(text) < BINLOG_CHECKSUM_LEN {
+			return nil, errors.New("Invalid IncidentEvent len")
+		}
+
+		text = text[:len(text)-BINLOG_CHECKSUM_LEN]
+	}
+
+	if len(text) < INCIDENT_HEADER_LEN {
+		return nil, errors.New("Invalid IncidentEvent len")
+	}
+
+	event := &IncidentEvent{header: header, Incident: binary.LittleEndian.Uint16(text)}
+	text = text[INCIDENT_HEADER_LEN:]
+
+	// the message is optional
+	if len(text) > 0 {
+		size := int(text[0])
+		if 1+size > len(text) {
+			return nil, errors.New("Invalid IncidentEvent message len")
+		}
+
+		event.Message = string(text[1 : 1+size])
+	}
+
+	return event, nil
+}
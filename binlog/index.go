@@ -0,0 +1,144 @@
+//
+// index.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// The binlog index file of mysqld and reading all the binlogs in it
+//
+
+package binlog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadBinlogIndex reads the binlog index file, e.g. mysql-bin.index, and
+// returns the binlog paths in order. The relative paths in the index, which
+// are relative to the data directory, are resolved against the directory of
+// the index file.
+func ReadBinlogIndex(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var files []string
+	dir := filepath.Dir(path)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+
+		files = append(files, name)
+	}
+
+	return files, scanner.Err()
+}
+
+// MultiFileParser reads the events of a list of binlogs one after another
+type MultiFileParser struct {
+	files  []string
+	index  int // of the current file
+	file   *os.File
+	parser *Parser
+}
+
+// NewMultiFileParser returns a parser of all the binlogs in the index file
+func NewMultiFileParser(indexPath string) (*MultiFileParser, error) {
+	files, err := ReadBinlogIndex(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMultiFileParserFromFiles(files)
+}
+
+func NewMultiFileParserFromFiles(files []string) (*MultiFileParser, error) {
+	if len(files) == 0 {
+		return nil, errors.New("No binlog files")
+	}
+
+	parser := &MultiFileParser{files: files, index: -1}
+	if err := parser.next(); err == io.EOF {
+		return nil, ErrNoEvents
+	} else if err != nil {
+		return nil, err
+	}
+
+	return parser, nil
+}
+
+// next opens the next binlog which has events, the binlogs with only the
+// magic, e.g. one just rotated to, are skipped. It returns io.EOF if there is
+// none. The current binlog is kept on an error, so that the next ReadEvent
+// tries the same binlog again.
+func (self *MultiFileParser) next() error {
+	for index := self.index + 1; index < len(self.files); index++ {
+		file, err := os.Open(self.files[index])
+		if err != nil {
+			return err
+		}
+
+		parser, err := NewParser(file)
+		if err == ErrNoEvents {
+			file.Close()
+			continue
+		} else if err != nil {
+			file.Close()
+			return err
+		}
+
+		self.Close()
+		self.index, self.file, self.parser = index, file, parser
+		return nil
+	}
+
+	return io.EOF
+}
+
+// File returns the path of the binlog being read
+func (self *MultiFileParser) File() string {
+	return self.files[self.index]
+}
+
+// Parser returns the parser of the binlog being read
+func (self *MultiFileParser) Parser() *Parser {
+	return self.parser
+}
+
+// ReadEvent reads the next event, it returns io.EOF at the end of the last
+// binlog
+func (self *MultiFileParser) ReadEvent() (BinLogEvent, error) {
+	for {
+		event, err := self.parser.ReadEvent()
+		if err != io.EOF {
+			return event, err
+		}
+
+		if err = self.next(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (self *MultiFileParser) Close() error {
+	if self.file == nil {
+		return nil
+	}
+
+	err := self.file.Close()
+	self.file, self.parser = nil, nil
+	return err
+}
@@ -0,0 +1,112 @@
+//
+// index_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeBinlogs writes the binlogs and their index file to a temporary
+// directory, a nil binlog is left out of the directory but not the index
+func writeBinlogs(t *testing.T, binlogs ...[]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	var index strings.Builder
+	for i, data := range binlogs {
+		name := fmt.Sprintf("mysql-bin.%06d", i+1)
+		index.WriteString("./" + name + "\n")
+		if data == nil {
+			continue
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(dir, "mysql-bin.index")
+	if err := ioutil.WriteFile(path, []byte(index.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestMultiFileParser(t *testing.T) {
+	magic := []byte{0xfe, 'b', 'i', 'n'}
+	first, second := unhex(t, fde57+rowsSecret), unhex(t, fde57+querySchemas)
+	tests := []struct {
+		name    string
+		binlogs [][]byte
+		events  int
+		file    string // the binlog of the last event
+	}{
+		{"one binlog", [][]byte{first}, 6, "mysql-bin.000001"},
+		{"two binlogs", [][]byte{first, second}, 11, "mysql-bin.000002"},
+		{"magic only in the middle", [][]byte{first, magic, second}, 11, "mysql-bin.000003"},
+		{"magic only first", [][]byte{magic, second}, 5, "mysql-bin.000002"},
+		{"magic only last", [][]byte{first, second, magic}, 11, "mysql-bin.000002"},
+	}
+
+	for _, test := range tests {
+		parser, err := NewMultiFileParser(writeBinlogs(t, test.binlogs...))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		n := 0
+		for ; ; n++ {
+			if _, err = parser.ReadEvent(); err != nil {
+				break
+			}
+		}
+
+		if err != io.EOF || n != test.events {
+			t.Errorf("%s: got %d events and %v, want %d", test.name, n, err, test.events)
+		}
+
+		if filepath.Base(parser.File()) != test.file {
+			t.Errorf("%s: got %s, want %s", test.name, parser.File(), test.file)
+		}
+
+		parser.Close()
+	}
+
+	if _, err := NewMultiFileParser(writeBinlogs(t, magic, magic)); err != ErrNoEvents {
+		t.Errorf("magic only: got %v, want %v", err, ErrNoEvents)
+	}
+}
+
+func TestMultiFileParserMissingFile(t *testing.T) {
+	parser, err := NewMultiFileParser(writeBinlogs(t, unhex(t, fde57+querySchemas), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer parser.Close()
+	for i := 0; i < 5; i++ {
+		if _, err = parser.ReadEvent(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the error is the same on a retry, and the first binlog is kept
+	for i := 0; i < 2; i++ {
+		if _, err = parser.ReadEvent(); !os.IsNotExist(err) {
+			t.Errorf("retry %d: got %v, want a missing file", i, err)
+		}
+
+		if filepath.Base(parser.File()) != "mysql-bin.000001" || parser.Parser() == nil {
+			t.Errorf("retry %d: got %s", i, parser.File())
+		}
+	}
+}
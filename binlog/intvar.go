@@ -0,0 +1,114 @@
+//
+// intvar.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// INTVAR_EVENT and RAND_EVENT, the session state used by the following
+// statement in statement based replication
+//
+
+package binlog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// types of INTVAR_EVENT
+const (
+	INVALID_INT_EVENT    = 0
+	LAST_INSERT_ID_EVENT = 1
+	INSERT_ID_EVENT      = 2
+)
+
+const (
+	INTVAR_EVENT_BODY_LEN = 1 + 8
+	RAND_EVENT_BODY_LEN   = 8 + 8
+)
+
+type IntvarEvent struct {
+	header *BinLogEventHeader
+	Type   uint8
+	Value  uint64
+}
+
+func (self *IntvarEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *IntvarEvent) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *IntvarEvent) GetPostHeader() []string {
+	return nil
+}
+
+func (self *IntvarEvent) GetPayload() []string {
+	return []string{
+		fmt.Sprintf("type: %d (%s)", self.Type, self.name()),
+		fmt.Sprintf("value: %d", self.Value),
+	}
+}
+
+func (self *IntvarEvent) name() string {
+	switch self.Type {
+	case LAST_INSERT_ID_EVENT:
+		return "LAST_INSERT_ID"
+	case INSERT_ID_EVENT:
+		return "INSERT_ID"
+	default:
+		return "INVALID_INT"
+	}
+}
+
+// SQL returns the statement which sets the variable like mysqlbinlog
+func (self *IntvarEvent) SQL() string {
+	return fmt.Sprintf("SET %s=%d", self.name(), self.Value)
+}
+
+func newIntvarEvent(header *BinLogEventHeader, text []byte) (*IntvarEvent, error) {
+	if len(text) < INTVAR_EVENT_BODY_LEN {
+		return nil, errors.New("Invalid IntvarEvent len")
+	}
+
+	return &IntvarEvent{header, text[0], binary.LittleEndian.Uint64(text[1:])}, nil
+}
+
+type RandEvent struct {
+	header *BinLogEventHeader
+	Seed1  uint64
+	Seed2  uint64
+}
+
+func (self *RandEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *RandEvent) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *RandEvent) GetPostHeader() []string {
+	return nil
+}
+
+func (self *RandEvent) GetPayload() []string {
+	return []string{
+		fmt.Sprintf("seed1: %d", self.Seed1),
+		fmt.Sprintf("seed2: %d", self.Seed2),
+	}
+}
+
+// SQL returns the statement which sets the seeds of RAND() like mysqlbinlog
+func (self *RandEvent) SQL() string {
+	return fmt.Sprintf("SET @@RAND_SEED1=%d, @@RAND_SEED2=%d", self.Seed1, self.Seed2)
+}
+
+func newRandEvent(header *BinLogEventHeader, text []byte) (*RandEvent, error) {
+	if len(text) < RAND_EVENT_BODY_LEN {
+		return nil, errors.New("Invalid RandEvent len")
+	}
+
+	return &RandEvent{header, binary.LittleEndian.Uint64(text), binary.LittleEndian.Uint64(text[8:])}, nil
+}
@@ -0,0 +1,376 @@
+//
+// json.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+func (self LogEventType) MarshalText() ([]byte, error) {
+	return []byte(self.String()), nil
+}
+
+func (self *LogEventType) UnmarshalText(text []byte) error {
+	t, err := ParseLogEventType(string(text))
+	if err != nil {
+		return err
+	}
+
+	*self = t
+	return nil
+}
+
+// EventJSON is the JSON representation of a BinLogEvent
+type EventJSON struct {
+	Header     *BinLogEventHeader `json:"header"`
+	PostHeader []string           `json:"post_header,omitempty"`
+	Payload    []string           `json:"payload,omitempty"`
+}
+
+func NewEventJSON(e BinLogEvent) *EventJSON {
+	return &EventJSON{
+		Header:     e.Header(),
+		PostHeader: e.GetPostHeader(),
+		Payload:    e.GetPayload(),
+	}
+}
+
+func MarshalEventJSON(e BinLogEvent) ([]byte, error) {
+	return json.Marshal(NewEventJSON(e))
+}
+
+// EventNDJSON is the object of an event in the newline delimited JSON
+// output. The envelope is the same for all the event types, the payload is
+// an object of the fields of the event type, see ndjsonPayload.
+type EventNDJSON struct {
+	Offset    int64        `json:"offset"`
+	Type      LogEventType `json:"type"`
+	Timestamp string       `json:"timestamp"` // RFC 3339 in UTC
+	ServerId  uint32       `json:"server_id"`
+	Payload   interface{}  `json:"payload"`
+}
+
+// NewEventNDJSON returns the NDJSON object of the event at offset of the
+// binlog
+func NewEventNDJSON(e BinLogEvent, offset int64) *EventNDJSON {
+	header := e.Header()
+	return &EventNDJSON{
+		Offset:    offset,
+		Type:      header.EventType,
+		Timestamp: header.Time(time.UTC).Format(time.RFC3339),
+		ServerId:  header.ServerId,
+		Payload:   ndjsonPayload(e),
+	}
+}
+
+// MarshalEventNDJSON encodes the event as one line of JSON without the
+// trailing newline
+func MarshalEventNDJSON(e BinLogEvent, offset int64) ([]byte, error) {
+	return json.Marshal(NewEventNDJSON(e, offset))
+}
+
+// the NDJSON payloads of the event types, the fields marked omitempty are
+// optional in the event. The strings which may not be valid UTF-8, i.e. the
+// queries and the schemas, are rendered by RenderText.
+
+type formatDescriptionPayload struct {
+	BinlogVersion         uint16 `json:"binlog_version"`
+	ServerVersion         string `json:"mysql_server_version"`
+	CreateTimestamp       uint32 `json:"create_timestamp"`
+	EventHeaderLength     uint8  `json:"event_header_length"`
+	EventTypeHeaderLength []int  `json:"event_type_header_length"` // indexed by event type - 1
+	ChecksumAlg           string `json:"checksum_alg"`
+}
+
+type queryPayload struct {
+	SlaveProxyId     uint32            `json:"slave_proxy_id"`
+	ExecutionTime    uint32            `json:"execution_time"`
+	SchemaLength     uint8             `json:"schema_length"`
+	ErrorCode        uint16            `json:"error_code"`
+	StatusVarsLength uint16            `json:"status_vars_length"`
+	StatusVars       map[string]string `json:"status_vars"` // keyed by the name, e.g. Q_SQL_MODE_CODE
+	Schema           string            `json:"schema"`
+	Query            string            `json:"query"`
+}
+
+type xidPayload struct {
+	Xid uint64 `json:"xid"`
+}
+
+type rotatePayload struct {
+	Position   uint64 `json:"position"`
+	NextBinlog string `json:"next_binlog"`
+}
+
+type previousGtidsPayload struct {
+	GtidSet string `json:"gtid_set"`
+}
+
+type gtidPayload struct {
+	Gtid                     string `json:"gtid"` // uuid:gno, or ANONYMOUS
+	CommitFlag               bool   `json:"commit_flag"`
+	LastCommitted            int64  `json:"last_committed"`
+	SequenceNumber           int64  `json:"sequence_number"`
+	OriginalCommitTimestamp  uint64 `json:"original_commit_timestamp"`  // microseconds since unix epoch, 0 if unknown
+	ImmediateCommitTimestamp uint64 `json:"immediate_commit_timestamp"` // microseconds since unix epoch, 0 if unknown
+	TransactionLength        uint64 `json:"transaction_length"`         // 0 if unknown
+}
+
+type incidentPayload struct {
+	Incident uint16 `json:"incident"`
+	Name     string `json:"name"`
+	Message  string `json:"message"`
+}
+
+type intvarPayload struct {
+	Type  uint8  `json:"type"`
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+}
+
+type randPayload struct {
+	Seed1 uint64 `json:"seed1"`
+	Seed2 uint64 `json:"seed2"`
+}
+
+type userVarPayload struct {
+	Name    string `json:"name"`
+	Type    uint8  `json:"type"` // Item_result
+	Charset uint32 `json:"charset"`
+	Flags   uint8  `json:"flags"`
+	IsNull  bool   `json:"is_null"`
+	Value   string `json:"value"` // as a SQL literal
+}
+
+type rowsQueryPayload struct {
+	Query string `json:"query"`
+}
+
+type slavePayload struct {
+	MasterPos  uint64 `json:"master_pos"`
+	MasterPort uint16 `json:"master_port"`
+	MasterHost string `json:"master_host"`
+	MasterLog  string `json:"master_log"`
+}
+
+type mariadbGtidPayload struct {
+	Gtid     string `json:"gtid"` // domain-server-seq
+	Flags    uint8  `json:"flags"`
+	CommitId uint64 `json:"commit_id"` // 0 without FL_GROUP_COMMIT_ID
+}
+
+type mariadbGtidListPayload struct {
+	Flags    uint8    `json:"flags"`
+	GtidList []string `json:"gtid_list"`
+}
+
+type binlogCheckpointPayload struct {
+	File string `json:"file"`
+}
+
+type ignorablePayload struct {
+	DataLength int `json:"data_length"`
+}
+
+type unknownPayload struct {
+	Ignorable bool `json:"ignorable"`
+}
+
+type tableMapColumn struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Meta     uint16 `json:"meta"`
+	Nullable bool   `json:"nullable"`
+	Unsigned bool   `json:"unsigned"`
+}
+
+type tableMapPayload struct {
+	TableId    uint64           `json:"table_id"`
+	Flags      uint16           `json:"flags"`
+	Schema     string           `json:"schema"`
+	Table      string           `json:"table"`
+	Columns    []tableMapColumn `json:"columns"`
+	PrimaryKey []string         `json:"primary_key"` // the column names, with the prefix length if any, e.g. name(10)
+}
+
+// rowsPayload has the rows keyed by column name, with the before and the
+// after image of each row of an update. Schema, Table and Rows are empty if
+// the rows can not be decoded, Error tells why.
+type rowsPayload struct {
+	TableId           uint64        `json:"table_id"`
+	Flags             uint16        `json:"flags"`
+	ExtraDataLength   int           `json:"extra_data_length,omitempty"`
+	PartitionId       *int          `json:"partition_id,omitempty"`
+	SourcePartitionId *int          `json:"source_partition_id,omitempty"`
+	NdbInfoFormat     *uint8        `json:"ndb_info_format,omitempty"`
+	NdbInfo           string        `json:"ndb_info,omitempty"` // hex
+	Schema            string        `json:"schema"`
+	Table             string        `json:"table"`
+	ColumnCount       int           `json:"column_count"`
+	Rows              []interface{} `json:"rows"`
+	Error             string        `json:"error,omitempty"`
+}
+
+// linesPayload is the text lines of the events of the types registered by
+// RegisterEventDecoder, whose fields are not known
+type linesPayload struct {
+	PostHeader []string `json:"post_header"`
+	Payload    []string `json:"payload"`
+}
+
+// ndjsonPayload returns the payload of the event, which is one of the payload
+// types of the event types
+func ndjsonPayload(e BinLogEvent) interface{} {
+	switch event := e.(type) {
+	case *FormatDescriptionEvent:
+		payload := event.payload
+		if payload == nil {
+			return &formatDescriptionPayload{EventTypeHeaderLength: []int{}, ChecksumAlg: event.ChecksumAlg.String()}
+		}
+
+		lengths := make([]int, len(payload.EventTypeHeaderLength))
+		for i, length := range payload.EventTypeHeaderLength {
+			lengths[i] = int(length)
+		}
+
+		return &formatDescriptionPayload{payload.BinlogVersion, payload.MySQLServerVersion, payload.CreateTimestamp,
+			payload.EventHeaderLength, lengths, event.ChecksumAlg.String()}
+	case *QueryEvent:
+		vars := make(map[string]string)
+		for key, val := range event.payload.StatusVars {
+			vars[fmt.Sprint(key)] = fmt.Sprint(val)
+		}
+
+		header := event.postHeader
+		return &queryPayload{header.SlaveProxyId, header.ExecutionTime, header.SchemaLength, header.ErrorCode,
+			header.StatusVarsLength, vars, RenderText(event.Schema()), RenderText(event.query())}
+	case *XidEvent:
+		return &xidPayload{event.xid}
+	case *RotateEvent:
+		return &rotatePayload{event.position, event.nextBinlog}
+	case *PreviousGtidsLogEvent:
+		return &previousGtidsPayload{event.gtidSet.String()}
+	case *GtidLogEvent:
+		return &gtidPayload{event.String(), event.CommitFlag, event.LastCommitted, event.SequenceNumber,
+			event.OriginalCommitTimestamp, event.ImmediateCommitTimestamp, event.TransactionLength}
+	case *IncidentEvent:
+		return &incidentPayload{event.Incident, event.Name(), event.Message}
+	case *IntvarEvent:
+		return &intvarPayload{event.Type, event.name(), event.Value}
+	case *RandEvent:
+		return &randPayload{event.Seed1, event.Seed2}
+	case *UserVarEvent:
+		return &userVarPayload{event.Name, event.Type, event.Charset, event.Flags, event.IsNull, event.FormatValue()}
+	case *RowsQueryEvent:
+		return &rowsQueryPayload{RenderText(event.output())}
+	case *SlaveEvent:
+		return &slavePayload{event.masterPos, event.masterPort, event.masterHost, event.masterLog}
+	case *MariadbGtidEvent:
+		return &mariadbGtidPayload{event.Gtid.String(), event.Flags, event.CommitId}
+	case *MariadbGtidListEvent:
+		list := []string{}
+		for _, gtid := range event.List {
+			list = append(list, gtid.String())
+		}
+
+		return &mariadbGtidListPayload{event.Flags, list}
+	case *BinlogCheckpointEvent:
+		return &binlogCheckpointPayload{event.File}
+	case *IgnorableLogEvent:
+		return &ignorablePayload{event.size}
+	case *UnknownBinLogEvent:
+		return &unknownPayload{event.Ignorable()}
+	case *TableMapEvent:
+		return event.ndjsonPayload()
+	case *RowsEvent:
+		return event.ndjsonPayload()
+	default:
+		return &linesPayload{e.GetPostHeader(), e.GetPayload()}
+	}
+}
+
+func (self *TableMapEvent) ndjsonPayload() *tableMapPayload {
+	payload := &tableMapPayload{TableId: self.TableId, Flags: self.Flags, Schema: self.Schema, Table: self.Table,
+		Columns: []tableMapColumn{}, PrimaryKey: []string{}}
+	for i, t := range self.ColumnTypes {
+		name := t.String()
+		if t == MYSQL_TYPE_TYPED_ARRAY {
+			name = fmt.Sprintf("%v(%v)", t, ColumnType(self.ColumnMeta[i]))
+		}
+
+		payload.Columns = append(payload.Columns, tableMapColumn{self.ColumnName(i), name, self.ColumnMeta[i],
+			self.IsNullable(i), self.isUnsigned(i)})
+	}
+
+	if self.Metadata != nil {
+		for n, col := range self.Metadata.PrimaryKey {
+			key := self.ColumnName(col)
+			if prefix := self.Metadata.PrimaryKeyPrefix[n]; prefix != 0 {
+				key = fmt.Sprintf("%s(%d)", key, prefix)
+			}
+
+			payload.PrimaryKey = append(payload.PrimaryKey, key)
+		}
+	}
+
+	return payload
+}
+
+func (self *RowsEvent) ndjsonPayload() *rowsPayload {
+	payload := &rowsPayload{TableId: self.tableId, Flags: self.flags, ColumnCount: self.columnCount,
+		Rows: []interface{}{}}
+	if self.extraData != nil {
+		payload.ExtraDataLength = len(self.extraData) + 2
+	}
+
+	if self.partitionId >= 0 {
+		payload.PartitionId = &self.partitionId
+	}
+
+	if self.sourcePartId >= 0 {
+		payload.SourcePartitionId = &self.sourcePartId
+	}
+
+	if self.ndbData != nil {
+		payload.NdbInfoFormat = &self.ndbFormat
+		payload.NdbInfo = hex.EncodeToString(self.ndbData)
+	}
+
+	if self.err != nil {
+		payload.Error = self.err.Error()
+	} else if self.tableMap != nil {
+		payload.Schema = self.tableMap.Schema
+		payload.Table = self.tableMap.Table
+		payload.Rows = self.rowImages()
+	}
+
+	return payload
+}
+
+// rowImages returns the images of the rows keyed by column name, with the
+// before and the after image of each row of an update
+func (self *RowsEvent) rowImages() []interface{} {
+	images := []interface{}{}
+	if !isUpdateRowsEvent(self.header.EventType) {
+		for _, row := range self.rows {
+			images = append(images, self.image(row, self.columns))
+		}
+
+		return images
+	}
+
+	for i := 0; i+1 < len(self.rows); i += 2 {
+		images = append(images, map[string]interface{}{
+			"before": self.image(self.rows[i], self.columns),
+			"after":  self.image(self.rows[i+1], self.columnsAfter),
+		})
+	}
+
+	return images
+}
@@ -0,0 +1,49 @@
+//
+// json_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"testing"
+)
+
+// a CREATE TABLE in the test schema of a MySQL 8.0.18 binlog
+const query80 = `
+	# QUERY_EVENT at 202
+	80 4c 94 5d 02 01 00 00 00 53 00 00 00 1d 01 00 00 00 00 05 00 00 00 00
+	00 00 00 04 00 00 15 00 00 00 00 00 00 01 00 00 00 40 00 00 00 00 04 21
+	00 21 00 08 00 74 65 73 74 00 63 72 65 61 74 65 20 74 61 62 6c 65 20 78
+	28 61 20 69 6e 74 29 23 8e 46 e5
+`
+
+func TestMarshalEventNDJSON(t *testing.T) {
+	events := readEvents(t, fde80+gtid80+query80)
+	tests := []struct {
+		offset int64
+		want   string
+	}{
+		{4, `{"offset":4,"type":"FORMAT_DESCRIPTION_EVENT","timestamp":"2019-10-02T07:06:40Z","server_id":1,` +
+			`"payload":{"binlog_version":4,"mysql_server_version":"8.0.18","create_timestamp":0,"event_header_length":19,` +
+			`"event_type_header_length":[56,13,0,8,0,18,0,4,4,4,4,18,0,0,95,0,4,26,8,0,0,0,8,8,8,2,0,0,0,10,10,10,42,42,0,18,52,0],` +
+			`"checksum_alg":"CRC32"}}`},
+		{123, `{"offset":123,"type":"GTID_LOG_EVENT","timestamp":"2019-10-02T07:06:40Z","server_id":1,` +
+			`"payload":{"gtid":"3e11fa47-71ca-11e1-9e33-c80aa9429562:9","commit_flag":true,"last_committed":0,` +
+			`"sequence_number":1,"original_commit_timestamp":1570000000000000,` +
+			`"immediate_commit_timestamp":1570000000123456,"transaction_length":0}}`},
+		{202, `{"offset":202,"type":"QUERY_EVENT","timestamp":"2019-10-02T07:06:40Z","server_id":1,` +
+			`"payload":{"slave_proxy_id":5,"execution_time":0,"schema_length":4,"error_code":0,"status_vars_length":21,` +
+			`"status_vars":{"Q_CHARSET_CODE":"[33 33 8]","Q_FLAGS2_CODE":"(none)","Q_SQL_MODE_CODE":"MODE_NO_ENGINE_SUBSTITUTION"},` +
+			`"schema":"test","query":"create table x(a int)"}}`},
+	}
+
+	for i, test := range tests {
+		text, err := MarshalEventNDJSON(events[i], test.offset)
+		if err != nil {
+			t.Errorf("%v: %v", events[i].Header().EventType, err)
+		} else if string(text) != test.want {
+			t.Errorf("%v: got %s, want %s", events[i].Header().EventType, text, test.want)
+		}
+	}
+}
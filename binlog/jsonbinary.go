@@ -0,0 +1,160 @@
+//
+// jsonbinary.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// JSON column values, which are stored as binary JSON, see sql/json_binary.cc
+//
+
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// jsonObject is a decoded JSON object, the members are in the order of the
+// binary JSON, which sorts the keys by length and then by bytes
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	key   string
+	value Any
+}
+
+func (self jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, member := range self {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := jsonText(member.key)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := jsonText(member.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteString(key)
+		buf.WriteByte(':')
+		buf.WriteString(value)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonText returns val as JSON text, without escaping <, > and & for HTML
+func jsonText(val Any) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(val); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// decodeJSON decodes the binary JSON of a JSON column to JSON text, which is
+// the type of the document followed by its value. An empty value is the JSON
+// null like in Field_json::val_json.
+func decodeJSON(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "null", nil
+	}
+
+	val, err := decodeJSONValue(data[0], data[1:])
+	if err != nil {
+		return "", err
+	}
+
+	return jsonText(val)
+}
+
+// decodeJSONContainer decodes an object or an array, which is the element
+// count and the size in bytes, followed by the key entries of an object, the
+// value entries, and the keys and the values they refer to. A key entry is the
+// offset and the length of the key, a value entry is the type and the inlined
+// value or the offset of the value. The counts, sizes and offsets are 2 bytes
+// in a small container and 4 bytes in a large one, the offsets are relative
+// to the start of the container.
+func decodeJSONContainer(doc []byte, size int, object bool) (Any, error) {
+	if len(doc) < 2*size {
+		return nil, errors.New("Invalid JSON container len")
+	}
+
+	count := int(jsonUint(doc, size))
+	keyEntry, valueEntry := 0, 1+size
+	if object {
+		keyEntry = size + 2
+	}
+
+	if count > (len(doc)-2*size)/(keyEntry+valueEntry) {
+		return nil, errors.New("Invalid JSON container count")
+	}
+
+	values := make([]Any, count)
+	for i := range values {
+		pos := 2*size + count*keyEntry + i*valueEntry
+		val, err := decodeJSONEntry(doc, doc[pos], doc[pos+1:pos+valueEntry])
+		if err != nil {
+			return nil, err
+		}
+
+		values[i] = val
+	}
+
+	if !object {
+		return values, nil
+	}
+
+	members := make(jsonObject, count)
+	for i := range members {
+		pos := 2*size + i*keyEntry
+		offset := int(jsonUint(doc[pos:], size))
+		length := int(binary.LittleEndian.Uint16(doc[pos+size:]))
+		if offset > len(doc) || length > len(doc)-offset {
+			return nil, errors.New("Invalid JSON key offset")
+		}
+
+		members[i] = jsonMember{string(doc[offset : offset+length]), values[i]}
+	}
+
+	return members, nil
+}
+
+// formatPackedTime formats a temporal value of a JSON document, which is
+// packed in an integer like in memory, see TIME_from_longlong_datetime_packed
+// and TIME_from_longlong_time_packed in sql-common/my_time.c
+func formatPackedTime(t ColumnType, packed int64) string {
+	sign := ""
+	if packed < 0 {
+		sign = "-"
+		packed = -packed
+	}
+
+	intpart, usec := packed>>24, packed%(1<<24)
+	hms := intpart % (1 << 17)
+	if t == MYSQL_TYPE_TIME {
+		return fmt.Sprintf("%s%02d:%02d:%02d.%06d", sign, (intpart>>12)%(1<<10), (intpart>>6)%(1<<6),
+			intpart%(1<<6), usec)
+	}
+
+	ymd := intpart >> 17
+	ym := ymd >> 5
+	if t == MYSQL_TYPE_DATE {
+		return fmt.Sprintf("%s%04d-%02d-%02d", sign, ym/13, ym%13, ymd%(1<<5))
+	}
+
+	return fmt.Sprintf("%s%04d-%02d-%02d %02d:%02d:%02d.%06d", sign, ym/13, ym%13, ymd%(1<<5),
+		hms>>12, (hms>>6)%(1<<6), hms%(1<<6), usec)
+}
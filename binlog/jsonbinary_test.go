@@ -0,0 +1,74 @@
+//
+// jsonbinary_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "null"},
+		{"true", []byte{0x04, 0x01}, "true"},
+		{"int16", []byte{0x05, 0xfe, 0xff}, "-2"},
+		{"uint64", []byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "18446744073709551615"},
+		{"double", []byte{0x0b, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f}, "1.5"},
+		{"string", []byte{0x0c, 0x06, 'h', 0xc3, 0xa9, 'l', 'l', 'o'}, `"héllo"`},
+		// {"a": [1, "x<y"], "b": null}, the array is after the keys
+		{"small object", []byte{
+			0x00, 0x02, 0x00, 0x22, 0x00,
+			0x12, 0x00, 0x01, 0x00, 0x13, 0x00, 0x01, 0x00,
+			0x02, 0x14, 0x00, 0x04, 0x00, 0x00,
+			'a', 'b',
+			0x02, 0x00, 0x0e, 0x00, 0x05, 0x01, 0x00, 0x0c, 0x0a, 0x00, 0x03, 'x', '<', 'y',
+		}, `{"a":[1,"x<y"],"b":null}`},
+		// [70000], the int32 is inlined in a large array
+		{"large array", []byte{
+			0x03, 0x01, 0x00, 0x00, 0x00, 0x0d, 0x00, 0x00, 0x00, 0x07, 0x70, 0x11, 0x01, 0x00,
+		}, "[70000]"},
+		{"decimal", []byte{0x0f, 0xf6, 0x07, 0x0a, 0x02, 0x80, 0x00, 0x04, 0xd2, 0x38}, "1234.56"},
+		{"datetime", []byte{0x0f, 0x0c, 0x08, 0x20, 0xa1, 0x07, 0xb8, 0xc8, 0x60, 0xa4, 0x19},
+			`"2019-10-16 12:34:56.500000"`},
+		{"date", []byte{0x0f, 0x0a, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x60, 0xa4, 0x19}, `"2019-10-16"`},
+		{"time", []byte{0x0f, 0x0b, 0x08, 0x00, 0x00, 0x00, 0x7d, 0xef, 0xff, 0xff, 0xff},
+			`"-01:02:03.000000"`},
+	}
+
+	for _, test := range tests {
+		text, err := decodeJSON(test.data)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if text != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, text, test.want)
+		}
+	}
+}
+
+func TestDecodeJSONInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"literal", []byte{0x04, 0x03}},
+		{"type", []byte{0x0e}},
+		{"truncated string", []byte{0x0c, 0x05, 'a'}},
+		{"object count", []byte{0x00, 0xff, 0x00, 0x04, 0x00}},
+		{"key offset", []byte{0x00, 0x01, 0x00, 0x0b, 0x00, 0xff, 0x00, 0x01, 0x00, 0x04, 0x00, 0x00}},
+		{"value offset", []byte{0x02, 0x01, 0x00, 0x07, 0x00, 0x0c, 0xff, 0x00}},
+		{"blob", []byte{0x0f, 0xfc, 0x01, 0x00}},
+		{"decimal", []byte{0x0f, 0xf6, 0x03, 0x00, 0x00, 0x80}},
+	}
+
+	for _, test := range tests {
+		if text, err := decodeJSON(test.data); err == nil {
+			t.Errorf("%s: got %s, want an error", test.name, text)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+//
+// legacy.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// events of the binlogs written by MySQL 4.x and 5.0
+//
+
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// START_EVENT_V3, the format description of binlog version 1 and 3, which is
+// replaced by FORMAT_DESCRIPTION_EVENT since binlog version 4
+type StartEventV3 struct {
+	header             *BinLogEventHeader
+	BinlogVersion      uint16
+	MySQLServerVersion string
+	CreateTimestamp    uint32
+}
+
+func (self *StartEventV3) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *StartEventV3) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *StartEventV3) GetPostHeader() []string {
+	return nil
+}
+
+func (self *StartEventV3) GetPayload() []string {
+	return []string{
+		fmt.Sprintf("binlog_version: %d", self.BinlogVersion),
+		fmt.Sprintf("mysql_server_version: %s", self.MySQLServerVersion),
+		fmt.Sprintf("create_timestamp: %d", self.CreateTimestamp),
+	}
+}
+
+func newStartEventV3(header *BinLogEventHeader, text []byte) (*StartEventV3, error) {
+	if len(text) < 2+50+4 {
+		return nil, errors.New("Invalid StartEventV3 len")
+	}
+
+	event := new(StartEventV3)
+	event.header = header
+	event.BinlogVersion = binary.LittleEndian.Uint16(text)
+	sversion := text[2 : 2+50]
+	if i := bytes.IndexByte(sversion, 0); i >= 0 {
+		sversion = sversion[:i]
+	}
+
+	event.MySQLServerVersion = string(sversion)
+	event.CreateTimestamp = binary.LittleEndian.Uint32(text[2+50:])
+	return event, nil
+}
+
+// SLAVE_EVENT, which is never written by any released version of MySQL
+type SlaveEvent struct {
+	header     *BinLogEventHeader
+	masterPos  uint64
+	masterPort uint16
+	masterHost string
+	masterLog  string
+}
+
+func (self *SlaveEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *SlaveEvent) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *SlaveEvent) GetPostHeader() []string {
+	return nil
+}
+
+func (self *SlaveEvent) GetPayload() []string {
+	return []string{
+		fmt.Sprintf("master_pos: %d", self.masterPos),
+		fmt.Sprintf("master_port: %d", self.masterPort),
+		fmt.Sprintf("master_host: %s", self.masterHost),
+		fmt.Sprintf("master_log: %s", self.masterLog),
+	}
+}
+
+func newSlaveEvent(header *BinLogEventHeader, text []byte,
+	fde *FormatDescriptionEvent) (*SlaveEvent, error) {
+
+	end := len(text)
+	if fde.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
+		end -= BINLOG_CHECKSUM_LEN
+	}
+
+	if end < 8+2 {
+		return nil, errors.New("Invalid SlaveEvent len")
+	}
+
+	event := new(SlaveEvent)
+	event.header = header
+	event.masterPos = binary.LittleEndian.Uint64(text)
+	event.masterPort = binary.LittleEndian.Uint16(text[8:])
+	host := text[8+2 : end]
+	if i := bytes.IndexByte(host, 0); i >= 0 {
+		event.masterHost = string(host[:i])
+		event.masterLog = string(bytes.TrimRight(host[i+1:], "\x00"))
+	} else {
+		event.masterHost = string(host)
+	}
+
+	return event, nil
+}
@@ -0,0 +1,29 @@
+//
+// logger.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+// Logger receives the diagnostics of a Parser which do not stop the parsing,
+// e.g. the rows of an event which can not be decoded. The methods are called
+// in the goroutine reading the events.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Warnf(format string, args ...interface{})  {}
+
+// SetLogger sets the Logger of the parser, nil restores the default one which
+// does nothing
+func (self *Parser) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = nopLogger{}
+	}
+
+	self.logger = logger
+}
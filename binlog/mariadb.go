@@ -0,0 +1,219 @@
+//
+// mariadb.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// The events of MariaDB, whose type numbers do not overlap with MySQL's
+//
+
+package binlog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	ANNOTATE_ROWS_EVENT     LogEventType = 160 // the statement of the following rows events
+	BINLOG_CHECKPOINT_EVENT LogEventType = 161
+	GTID_EVENT              LogEventType = 162 // MariaDB GTID, which replaces BEGIN
+	GTID_LIST_EVENT         LogEventType = 163 // the GTID state at the start of the binlog
+	START_ENCRYPTION_EVENT  LogEventType = 164
+)
+
+// flags2 of GTID_EVENT
+const (
+	FL_STANDALONE      = 0x01 // a single statement without BEGIN/COMMIT
+	FL_GROUP_COMMIT_ID = 0x02
+	FL_TRANSACTIONAL   = 0x04
+	FL_ALLOW_PARALLEL  = 0x08
+	FL_WAITED          = 0x10
+	FL_DDL             = 0x20
+	FL_PREPARED_XA     = 0x40
+	FL_COMPLETED_XA    = 0x80
+)
+
+// MariadbGtid is a GTID of MariaDB, whose server id is the one of the server
+// which wrote the transaction first
+type MariadbGtid struct {
+	DomainId uint32
+	ServerId uint32
+	SeqNo    uint64
+}
+
+// String returns the GTID in the form of gtid_current_pos, e.g. 0-1-100
+func (self MariadbGtid) String() string {
+	return fmt.Sprintf("%d-%d-%d", self.DomainId, self.ServerId, self.SeqNo)
+}
+
+type MariadbGtidEvent struct {
+	header   *BinLogEventHeader
+	Gtid     MariadbGtid
+	Flags    uint8
+	CommitId uint64 // 0 without FL_GROUP_COMMIT_ID
+}
+
+func (self *MariadbGtidEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *MariadbGtidEvent) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *MariadbGtidEvent) GetPostHeader() []string {
+	return nil
+}
+
+func (self *MariadbGtidEvent) GetPayload() []string {
+	val := []string{
+		fmt.Sprintf("gtid: %v", self.Gtid),
+		fmt.Sprintf("flags: 0x%x", self.Flags),
+	}
+
+	if self.Flags&FL_GROUP_COMMIT_ID != 0 {
+		val = append(val, fmt.Sprintf("commit_id: %d", self.CommitId))
+	}
+
+	return val
+}
+
+func (self *MariadbGtidEvent) String() string {
+	return self.Gtid.String()
+}
+
+// IsStandalone reports whether the transaction is a single statement, e.g.
+// DDL, which is not enclosed by BEGIN/COMMIT
+func (self *MariadbGtidEvent) IsStandalone() bool {
+	return self.Flags&FL_STANDALONE != 0
+}
+
+func newMariadbGtidEvent(header *BinLogEventHeader, text []byte,
+	fde *FormatDescriptionEvent) (*MariadbGtidEvent, error) {
+
+	end := len(text)
+	if fde.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
+		end -= BINLOG_CHECKSUM_LEN
+	}
+
+	// seq_no + domain_id + flags2
+	if end < 8+4+1 {
+		return nil, errors.New("Invalid MariadbGtidEvent len")
+	}
+
+	event := new(MariadbGtidEvent)
+	event.header = header
+	event.Gtid.SeqNo = binary.LittleEndian.Uint64(text)
+	event.Gtid.DomainId = binary.LittleEndian.Uint32(text[8:])
+	event.Gtid.ServerId = header.ServerId
+	event.Flags = text[12]
+	if event.Flags&FL_GROUP_COMMIT_ID != 0 {
+		if end < 13+8 {
+			return nil, errors.New("Invalid MariadbGtidEvent commit_id len")
+		}
+
+		event.CommitId = binary.LittleEndian.Uint64(text[13:])
+	}
+
+	return event, nil
+}
+
+type MariadbGtidListEvent struct {
+	header *BinLogEventHeader
+	Flags  uint8
+	List   []MariadbGtid
+}
+
+func (self *MariadbGtidListEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *MariadbGtidListEvent) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *MariadbGtidListEvent) GetPostHeader() []string {
+	return []string{
+		fmt.Sprintf("count: %d", len(self.List)),
+		fmt.Sprintf("flags: %d", self.Flags),
+	}
+}
+
+func (self *MariadbGtidListEvent) GetPayload() []string {
+	val := []string{"gtid_list:"}
+	for _, gtid := range self.List {
+		val = append(val, fmt.Sprintf("\t%v", gtid))
+	}
+
+	return val
+}
+
+func newMariadbGtidListEvent(header *BinLogEventHeader, text []byte,
+	fde *FormatDescriptionEvent) (*MariadbGtidListEvent, error) {
+
+	end := len(text)
+	if fde.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
+		end -= BINLOG_CHECKSUM_LEN
+	}
+
+	if end < 4 {
+		return nil, errors.New("Invalid MariadbGtidListEvent len")
+	}
+
+	// the count is in the low 28 bits, the flags in the high 4 bits
+	v := binary.LittleEndian.Uint32(text)
+	count := int(v & (1<<28 - 1))
+	if count > (end-4)/16 {
+		return nil, fmt.Errorf("Invalid MariadbGtidListEvent count %d", count)
+	}
+
+	event := &MariadbGtidListEvent{header, uint8(v >> 28), make([]MariadbGtid, count)}
+	for i := range event.List {
+		b := text[4+i*16:]
+		event.List[i] = MariadbGtid{binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint32(b[4:]),
+			binary.LittleEndian.Uint64(b[8:])}
+	}
+
+	return event, nil
+}
+
+type BinlogCheckpointEvent struct {
+	header *BinLogEventHeader
+	File   string // the oldest binlog needed for the crash recovery
+}
+
+func (self *BinlogCheckpointEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *BinlogCheckpointEvent) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *BinlogCheckpointEvent) GetPostHeader() []string {
+	return nil
+}
+
+func (self *BinlogCheckpointEvent) GetPayload() []string {
+	return []string{fmt.Sprintf("file: %s", self.File)}
+}
+
+func newBinlogCheckpointEvent(header *BinLogEventHeader, text []byte,
+	fde *FormatDescriptionEvent) (*BinlogCheckpointEvent, error) {
+
+	end := len(text)
+	if fde.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
+		end -= BINLOG_CHECKSUM_LEN
+	}
+
+	if end < 4 {
+		return nil, errors.New("Invalid BinlogCheckpointEvent len")
+	}
+
+	size := binary.LittleEndian.Uint32(text)
+	if uint64(size) > uint64(end-4) {
+		return nil, fmt.Errorf("Invalid BinlogCheckpointEvent file len %d", size)
+	}
+
+	return &BinlogCheckpointEvent{header, string(text[4 : 4+size])}, nil
+}
@@ -0,0 +1,325 @@
+//
+// metadata.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// The optional metadata of TABLE_MAP_EVENT, written by MySQL 8.0.1+ as
+// configured by binlog_row_metadata
+//
+
+package binlog
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// optional metadata types, see Table_map_log_event::Optional_metadata_field_type
+const (
+	TABLE_MAP_SIGNEDNESS                   = 1
+	TABLE_MAP_DEFAULT_CHARSET              = 2
+	TABLE_MAP_COLUMN_CHARSET               = 3
+	TABLE_MAP_COLUMN_NAME                  = 4
+	TABLE_MAP_SET_STR_VALUE                = 5
+	TABLE_MAP_ENUM_STR_VALUE               = 6
+	TABLE_MAP_GEOMETRY_TYPE                = 7
+	TABLE_MAP_SIMPLE_PRIMARY_KEY           = 8
+	TABLE_MAP_PRIMARY_KEY_WITH_PREFIX      = 9
+	TABLE_MAP_ENUM_AND_SET_DEFAULT_CHARSET = 10
+	TABLE_MAP_ENUM_AND_SET_COLUMN_CHARSET  = 11
+	TABLE_MAP_COLUMN_VISIBILITY            = 12
+)
+
+// TableMetadata is the optional metadata of a table, the slices are indexed
+// by column and nil if the metadata is not in the TABLE_MAP_EVENT
+type TableMetadata struct {
+	ColumnNames      []string
+	Unsigned         []bool   // numeric columns only
+	ColumnCharsets   []uint64 // collation ids of the character, ENUM and SET columns
+	SetStrValues     [][]string
+	EnumStrValues    [][]string
+	GeometryTypes    []uint64
+	PrimaryKey       []int  // column indexes of the primary key
+	PrimaryKeyPrefix []int  // prefix length of each primary key column, 0 for the whole column
+	Visible          []bool // MySQL 8.0.23+, false for the invisible columns
+}
+
+// realType returns the type of the i-th column, with ENUM and SET told apart
+// from STRING by the metadata
+func (self *TableMapEvent) realType(i int) ColumnType {
+	t := self.ColumnTypes[i]
+	if t == MYSQL_TYPE_STRING {
+		t, _ = unpackStringMeta(self.ColumnMeta[i])
+	}
+
+	return t
+}
+
+func isNumericType(t ColumnType) bool {
+	switch t {
+	case MYSQL_TYPE_TINY, MYSQL_TYPE_SHORT, MYSQL_TYPE_INT24, MYSQL_TYPE_LONG,
+		MYSQL_TYPE_LONGLONG, MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_FLOAT, MYSQL_TYPE_DOUBLE:
+		return true
+	default:
+		return false
+	}
+}
+
+func isCharacterType(t ColumnType) bool {
+	switch t {
+	case MYSQL_TYPE_STRING, MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_VARCHAR, MYSQL_TYPE_BLOB:
+		return true
+	default:
+		return false
+	}
+}
+
+func isEnumOrSetType(t ColumnType) bool {
+	return t == MYSQL_TYPE_ENUM || t == MYSQL_TYPE_SET
+}
+
+// columnsOf returns the indexes of the columns for which fn is true
+func (self *TableMapEvent) columnsOf(fn func(ColumnType) bool) []int {
+	var columns []int
+	for i := range self.ColumnTypes {
+		if fn(self.realType(i)) {
+			columns = append(columns, i)
+		}
+	}
+
+	return columns
+}
+
+// IsVisible reports whether the i-th column is visible, all the columns are
+// visible unless the COLUMN_VISIBILITY metadata of MySQL 8.0.23+ says not
+func (self *TableMapEvent) IsVisible(i int) bool {
+	return self.Metadata == nil || self.Metadata.Visible == nil || self.Metadata.Visible[i]
+}
+
+// isUnsigned reports whether the i-th column is an unsigned numeric column,
+// which is known only from the SIGNEDNESS metadata
+func (self *TableMapEvent) isUnsigned(i int) bool {
+	return self.Metadata != nil && self.Metadata.Unsigned != nil && self.Metadata.Unsigned[i]
+}
+
+// the bitmaps in the optional metadata are MSB first
+func bitSetMSB(bitmap []byte, i int) bool {
+	return i/8 < len(bitmap) && bitmap[i/8]&(0x80>>uint(i%8)) != 0
+}
+
+func readPackedString(r *bytes.Reader) (string, error) {
+	length, err := readPackedInt(r)
+	if err != nil {
+		return "", err
+	}
+
+	if length > uint64(r.Len()) {
+		return "", fmt.Errorf("Invalid string len %d", length)
+	}
+
+	buf := make([]byte, length)
+	_, err = readFull(r, buf)
+	return string(buf), err
+}
+
+// readCharsets reads the default charset followed by the pairs of index and
+// charset of the columns which do not use the default one
+func readCharsets(r *bytes.Reader, columns []int, charsets []uint64) error {
+	def, err := readPackedInt(r)
+	if err != nil {
+		return err
+	}
+
+	for _, col := range columns {
+		charsets[col] = def
+	}
+
+	for r.Len() > 0 {
+		idx, err := readPackedInt(r)
+		if err != nil {
+			return err
+		}
+
+		charset, err := readPackedInt(r)
+		if err != nil {
+			return err
+		}
+
+		if idx >= uint64(len(columns)) {
+			return fmt.Errorf("Invalid charset column index %d", idx)
+		}
+
+		charsets[columns[idx]] = charset
+	}
+
+	return nil
+}
+
+func readStrValues(r *bytes.Reader, columns []int, values [][]string) error {
+	for _, col := range columns {
+		if r.Len() == 0 {
+			break
+		}
+
+		count, err := readPackedInt(r)
+		if err != nil {
+			return err
+		}
+
+		if count > uint64(r.Len()) {
+			return fmt.Errorf("Invalid string value count %d", count)
+		}
+
+		values[col] = make([]string, count)
+		for i := range values[col] {
+			if values[col][i], err = readPackedString(r); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (self *TableMapEvent) parseOptionalMetadata(text []byte) error {
+	meta := new(TableMetadata)
+	count := len(self.ColumnTypes)
+	r := bytes.NewReader(text)
+	for r.Len() > 0 {
+		t, _ := r.ReadByte()
+		length, err := readPackedInt(r)
+		if err != nil {
+			return err
+		}
+
+		if length > uint64(r.Len()) {
+			return fmt.Errorf("Invalid optional metadata len %d", length)
+		}
+
+		value := make([]byte, length)
+		if _, err = readFull(r, value); err != nil {
+			return err
+		}
+
+		field := bytes.NewReader(value)
+		switch t {
+		case TABLE_MAP_SIGNEDNESS:
+			meta.Unsigned = make([]bool, count)
+			for n, col := range self.columnsOf(isNumericType) {
+				meta.Unsigned[col] = bitSetMSB(value, n)
+			}
+		case TABLE_MAP_DEFAULT_CHARSET, TABLE_MAP_COLUMN_CHARSET,
+			TABLE_MAP_ENUM_AND_SET_DEFAULT_CHARSET, TABLE_MAP_ENUM_AND_SET_COLUMN_CHARSET:
+			if meta.ColumnCharsets == nil {
+				meta.ColumnCharsets = make([]uint64, count)
+			}
+
+			columns := self.columnsOf(isCharacterType)
+			if t == TABLE_MAP_ENUM_AND_SET_DEFAULT_CHARSET || t == TABLE_MAP_ENUM_AND_SET_COLUMN_CHARSET {
+				columns = self.columnsOf(isEnumOrSetType)
+			}
+
+			if t == TABLE_MAP_DEFAULT_CHARSET || t == TABLE_MAP_ENUM_AND_SET_DEFAULT_CHARSET {
+				err = readCharsets(field, columns, meta.ColumnCharsets)
+				break
+			}
+
+			for _, col := range columns {
+				if meta.ColumnCharsets[col], err = readPackedInt(field); err != nil {
+					break
+				}
+			}
+		case TABLE_MAP_COLUMN_NAME:
+			meta.ColumnNames = make([]string, count)
+			for i := 0; i < count && err == nil; i++ {
+				meta.ColumnNames[i], err = readPackedString(field)
+			}
+		case TABLE_MAP_SET_STR_VALUE:
+			meta.SetStrValues = make([][]string, count)
+			err = readStrValues(field, self.columnsOf(func(t ColumnType) bool {
+				return t == MYSQL_TYPE_SET
+			}), meta.SetStrValues)
+		case TABLE_MAP_ENUM_STR_VALUE:
+			meta.EnumStrValues = make([][]string, count)
+			err = readStrValues(field, self.columnsOf(func(t ColumnType) bool {
+				return t == MYSQL_TYPE_ENUM
+			}), meta.EnumStrValues)
+		case TABLE_MAP_GEOMETRY_TYPE:
+			meta.GeometryTypes = make([]uint64, count)
+			for _, col := range self.columnsOf(func(t ColumnType) bool {
+				return t == MYSQL_TYPE_GEOMETRY
+			}) {
+				if meta.GeometryTypes[col], err = readPackedInt(field); err != nil {
+					break
+				}
+			}
+		case TABLE_MAP_COLUMN_VISIBILITY:
+			meta.Visible = make([]bool, count)
+			for i := range meta.Visible {
+				meta.Visible[i] = bitSetMSB(value, i)
+			}
+		case TABLE_MAP_SIMPLE_PRIMARY_KEY, TABLE_MAP_PRIMARY_KEY_WITH_PREFIX:
+			for field.Len() > 0 && err == nil {
+				var col, prefix uint64
+				if col, err = readPackedInt(field); err != nil {
+					break
+				}
+
+				if t == TABLE_MAP_PRIMARY_KEY_WITH_PREFIX {
+					if prefix, err = readPackedInt(field); err != nil {
+						break
+					}
+				}
+
+				if col >= uint64(count) {
+					err = fmt.Errorf("Invalid primary key column %d", col)
+					break
+				}
+
+				meta.PrimaryKey = append(meta.PrimaryKey, int(col))
+				meta.PrimaryKeyPrefix = append(meta.PrimaryKeyPrefix, int(prefix))
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("Optional metadata %d: %v", t, err)
+		}
+	}
+
+	self.Metadata = meta
+	return nil
+}
+
+// formatMetadata describes the optional metadata of the i-th column
+func (self *TableMapEvent) formatMetadata(i int) string {
+	meta := self.Metadata
+	if meta == nil {
+		return ""
+	}
+
+	desc := ""
+	if meta.Unsigned != nil && meta.Unsigned[i] {
+		desc += ", unsigned"
+	}
+
+	if meta.ColumnCharsets != nil && meta.ColumnCharsets[i] != 0 {
+		desc += fmt.Sprintf(", charset: %d", meta.ColumnCharsets[i])
+	}
+
+	if meta.EnumStrValues != nil && meta.EnumStrValues[i] != nil {
+		desc += fmt.Sprintf(", enum: %q", meta.EnumStrValues[i])
+	}
+
+	if meta.SetStrValues != nil && meta.SetStrValues[i] != nil {
+		desc += fmt.Sprintf(", set: %q", meta.SetStrValues[i])
+	}
+
+	if !self.IsVisible(i) {
+		desc += ", invisible"
+	}
+
+	if meta.GeometryTypes != nil && self.ColumnTypes[i] == MYSQL_TYPE_GEOMETRY {
+		desc += fmt.Sprintf(", geometry_type: %d", meta.GeometryTypes[i])
+	}
+
+	return desc
+}
@@ -0,0 +1,33 @@
+//
+// metrics.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+// Metrics receives the counters of a Parser, e.g. to export them to
+// Prometheus. The methods are called in the goroutine reading the events.
+type Metrics interface {
+	// an event is read and decoded
+	EventParsed(t LogEventType)
+	// an event failed to decode, t is UNKNOWN_EVENT if the header is not read
+	ParseError(t LogEventType)
+	// n bytes are read from the binlog
+	BytesRead(n int)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) EventParsed(t LogEventType) {}
+func (nopMetrics) ParseError(t LogEventType)  {}
+func (nopMetrics) BytesRead(n int)            {}
+
+// SetMetrics sets the Metrics of the parser, nil restores the default one
+// which does nothing
+func (self *Parser) SetMetrics(metrics Metrics) {
+	if metrics == nil {
+		metrics = nopMetrics{}
+	}
+
+	self.metrics = metrics
+}
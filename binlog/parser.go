@@ -6,59 +6,528 @@
 package binlog
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
 	"os"
 )
 
+var (
+	// ErrEmptyFile is returned by NewParser when the binlog ends before the
+	// magic number, e.g. a 0-byte binlog left by a crash during the rotation
+	ErrEmptyFile = errors.New("Empty binlog file")
+
+	// ErrNoEvents is returned by NewParser when a binlog file contains only
+	// the magic number
+	ErrNoEvents = errors.New("Binlog file contains no events")
+
+	// ErrNoFDE is returned in the strict FDE mode when an event is read or
+	// sought to before the FormatDescriptionEvent at the start of the binlog
+	ErrNoFDE = errors.New("No FormatDescriptionEvent before the event, read the binlog from the beginning")
+)
+
+// the binlog ends in the middle of an event
+var (
+	errEventHeader = errors.New("Failed to read event header")
+	errEventBody   = errors.New("Failed to read event body")
+)
+
+var binlogMagic = []byte{0xfe, 'b', 'i', 'n'}
+
 type Parser struct {
-	file *os.File
-	text []byte
-	fde  *FormatDescriptionEvent
+	reader     io.Reader
+	pos        int64 // offset of the next event
+	head       []byte
+	text       []byte
+	fde        *FormatDescriptionEvent
+	maxTxnSize uint64
+
+	// table maps of the current statement, by table_id
+	tableMaps map[uint64]*TableMapEvent
+	stmtEnd   bool
+
+	// relay log mode
+	relayLog  bool
+	relayFde  *FormatDescriptionEvent
+	masterFde *FormatDescriptionEvent
+
+	// progress report
+	progress         func(pos, total int64)
+	progressInterval int64
+	progressPos      int64
+	total            int64
+
+	metrics Metrics
+	logger  Logger
+
+	// timestamp check
+	timestampWarning func(pos int64, prev, cur uint32)
+	lastTimestamp    uint32
+
+	// the partially read event of ReadEventNonBlocking
+	pending []byte
+
+	// PREVIOUS_GTIDS and the GTIDs of the events read
+	executed GTIDSet
+
+	redact *RedactOptions
+
+	// header of the last event read or skipped, nil after a seek, and whether
+	// its bytes are still in head and text, i.e. it is not skipped
+	last     *BinLogEventHeader
+	lastRead bool
+
+	// end of the transaction of the last GTID event by its transaction_length
+	txnEnd int64
+
+	// whether the first event is read, which should be the FDE, see SetStrictFDE
+	fdeChecked bool
+	strictFDE  bool
+
+	errorPolicy ErrorPolicy
+}
+
+func (self *Parser) read(buf []byte) (int, error) {
+	n, err := io.ReadFull(self.reader, buf)
+	self.pos += int64(n)
+	if n > 0 {
+		self.metrics.BytesRead(n)
+	}
+
+	return n, err
 }
 
 func (self *Parser) readEventHeader() (*BinLogEventHeader, error) {
-	self.text = self.text[0:BINLOG_EVENT_HEADER_LEN]
-	n, err := self.file.Read(self.text)
+	n, err := self.read(self.head)
 	if err != nil {
+		if err == io.EOF {
+			self.reportProgress(true)
+			return nil, err
+		}
+
+		if err == io.ErrUnexpectedEOF {
+			return nil, errEventHeader
+		}
+
 		return nil, err
 	}
 
 	if n != BINLOG_EVENT_HEADER_LEN {
-		return nil, errors.New("Failed to read event header")
+		return nil, errEventHeader
+	}
+
+	header, err := NewBinLogEventHeader(self.head)
+	if err != nil {
+		return nil, err
 	}
 
-	return NewBinLogEventHeader(self.text)
+	if header.EventSize < BINLOG_EVENT_HEADER_LEN || header.EventSize > MAX_EVENT_SIZE {
+		return nil, fmt.Errorf("Invalid event size %d", header.EventSize)
+	}
+
+	self.checkTimestamp(header)
+	return header, nil
+}
+
+// SetTimestampWarningFunc sets a callback which is called with the offset of
+// the event when the timestamp of an event is less than the previous one,
+// which means clock skew on the master or binlogs concatenated by mistake.
+// The events with timestamp 0, e.g. the artificial ROTATE, are ignored.
+func (self *Parser) SetTimestampWarningFunc(fn func(pos int64, prev, cur uint32)) {
+	self.timestampWarning = fn
+	self.lastTimestamp = 0
+}
+
+func (self *Parser) checkTimestamp(header *BinLogEventHeader) {
+	if self.timestampWarning == nil || header.Timestamp == 0 {
+		return
+	}
+
+	if header.Timestamp < self.lastTimestamp {
+		self.timestampWarning(self.pos-BINLOG_EVENT_HEADER_LEN, self.lastTimestamp, header.Timestamp)
+	}
+
+	self.lastTimestamp = header.Timestamp
+}
+
+// Position returns the offset of the next event in the binlog
+func (self *Parser) Position() int64 {
+	return self.pos
+}
+
+// SetProgressFunc sets a callback which is called with the current position
+// and the size of the binlog after every interval bytes are read, and once
+// more at the end of the binlog. It is a no-op when the size is unknown,
+// i.e. the parser does not read from a regular *os.File.
+func (self *Parser) SetProgressFunc(fn func(pos, total int64), interval int64) {
+	self.progress = fn
+	self.progressInterval = interval
+	self.progressPos = self.pos
+	self.total = 0
+	if file, ok := self.reader.(*os.File); ok {
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+			self.total = info.Size()
+		}
+	}
+}
+
+func (self *Parser) reportProgress(force bool) {
+	if self.progress == nil || self.total <= 0 || self.pos == self.progressPos {
+		return
+	}
+
+	if force || self.pos-self.progressPos >= self.progressInterval {
+		self.progressPos = self.pos
+		self.progress(self.pos, self.total)
+	}
+}
+
+// whether the last 4 bytes of the event are a valid CRC32 checksum
+func (self *Parser) hasChecksum() bool {
+	n := len(self.text) - BINLOG_CHECKSUM_LEN
+	if n < 0 {
+		return false
+	}
+
+	crc := crc32.Update(crc32.ChecksumIEEE(self.head), crc32.IEEETable, self.text[:n])
+	return crc == binary.LittleEndian.Uint32(self.text[n:])
+}
+
+// In a relay log, the relay log's own events (its FDE, PREVIOUS_GTIDS and
+// the final ROTATE) use the slave's checksum setting, while the events
+// received by the IO thread, including the artificial ROTATE written before
+// the master's FDE, use the master's. So the checksum of a ROTATE event can
+// not be derived from the current FDE and is detected by verifying it.
+func (self *Parser) eventFde(header *BinLogEventHeader) *FormatDescriptionEvent {
+	if !self.relayLog || header.EventType != ROTATE_EVENT {
+		return self.fde
+	}
+
+	fde := new(FormatDescriptionEvent)
+	if self.hasChecksum() {
+		fde.ChecksumAlg = BINLOG_CHECKSUM_ALG_CRC32
+	}
+
+	return fde
+}
+
+// RelayLogFDE returns the relay log's own FormatDescriptionEvent, which is
+// written by the slave. It is nil when not in relay log mode or not read yet.
+func (self *Parser) RelayLogFDE() *FormatDescriptionEvent {
+	return self.relayFde
+}
+
+// MasterFDE returns the latest FormatDescriptionEvent of the master read from
+// a relay log, the following events are decoded with it.
+func (self *Parser) MasterFDE() *FormatDescriptionEvent {
+	return self.masterFde
+}
+
+// The event buffer grows to the largest event read, but a buffer larger than
+// MAX_RETAINED_BUFFER_SIZE is released when a smaller event follows, so that
+// one huge event does not keep its memory for the rest of the binlog.
+const (
+	INITIAL_BUFFER_SIZE      = 1024
+	MAX_RETAINED_BUFFER_SIZE = 1 << 20
+)
+
+func (self *Parser) resize(size uint32) {
+	if uint32(cap(self.text)) < size {
+		self.text = make([]byte, size)
+	} else if cap(self.text) > MAX_RETAINED_BUFFER_SIZE && size <= MAX_RETAINED_BUFFER_SIZE {
+		self.text = make([]byte, size, MAX_RETAINED_BUFFER_SIZE)
+	} else {
+		self.text = self.text[:size]
+	}
 }
 
+// ReadEvent reads and decodes the next event. The returned event owns its
+// data and stays valid after the following reads. With SKIP_CORRUPT the
+// events which can not be decoded are skipped, see SetErrorPolicy.
 func (self *Parser) ReadEvent() (BinLogEvent, error) {
 	if self.fde == nil {
 		self.fde = new(FormatDescriptionEvent)
 	}
 
-	header, err := self.readEventHeader()
+	for {
+		start := self.pos
+		header, err := self.readEventHeader()
+		if err != nil {
+			if err != io.EOF {
+				self.metrics.ParseError(UNKNOWN_EVENT)
+			}
+		} else {
+			var event BinLogEvent
+			event, err = self.readEvent(header)
+			if event, err = self.countEvent(header, event, err); err == nil {
+				return event, nil
+			}
+
+			// the body is not fully read
+			if self.pos != start+int64(header.EventSize) {
+				return nil, err
+			}
+		}
+
+		// the header is not fully read, the binlog is truncated or fails to read
+		if self.errorPolicy != SKIP_CORRUPT || err == ErrNoFDE || self.pos < start+BINLOG_EVENT_HEADER_LEN {
+			return nil, err
+		}
+
+		if err = self.skipCorrupt(start, header, err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// countEvent reports the decoded event or the error to the metrics, and the
+// events which are not fully decoded to the logger
+func (self *Parser) countEvent(header *BinLogEventHeader, event BinLogEvent, err error) (BinLogEvent, error) {
 	if err != nil {
+		self.metrics.ParseError(header.EventType)
 		return nil, err
 	}
 
-	size := header.EventSize - BINLOG_EVENT_HEADER_LEN
-	if size != 0 {
-		if uint32(cap(self.text)) < size {
-			self.text = make([]byte, size)
+	pos := self.pos - int64(header.EventSize)
+	switch e := event.(type) {
+	case *RowsEvent:
+		if e.err != nil {
+			self.metrics.ParseError(header.EventType)
+			self.logger.Warnf("Failed to decode the rows of the event at %d: %v", pos, e.err)
+			return event, nil
+		}
+	case *QueryEvent:
+		for key := range e.payload.StatusVars {
+			if !key.known() {
+				self.logger.Warnf("Unknown status var %v of the QueryEvent at %d, the status vars after it are not decoded",
+					key, pos)
+			}
+		}
+	case *UnknownBinLogEvent:
+		if e.Ignorable() {
+			self.logger.Debugf("Ignorable event %v at %d is not decoded", header.EventType, pos)
 		} else {
-			self.text = self.text[:size]
+			self.logger.Debugf("Event %v at %d is not decoded", header.EventType, pos)
+		}
+	case *FormatDescriptionEvent:
+		self.logger.Debugf("Binlog version %d, server version %s, checksum %v",
+			e.payload.BinlogVersion, e.payload.MySQLServerVersion, e.ChecksumAlg)
+	}
+
+	self.metrics.EventParsed(header.EventType)
+	return event, nil
+}
+
+// ReadEventNonBlocking is ReadEvent for a reader which returns what is
+// available without waiting, e.g. a net.Conn with a read deadline. It reads
+// at most once from the reader and returns (nil, false, nil) if the event is
+// not complete yet, the bytes read so far are kept for the next call. A read
+// which returns 0 bytes, io.EOF or a timeout error means no more bytes for
+// now, io.EOF is returned only at an event boundary. Do not mix it with the
+// other read and seek methods while an event is partially read.
+func (self *Parser) ReadEventNonBlocking() (BinLogEvent, bool, error) {
+	if self.fde == nil {
+		self.fde = new(FormatDescriptionEvent)
+	}
+
+	size, err := self.pendingEventSize()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(self.pending) < size {
+		n := len(self.pending)
+		if cap(self.pending)-n < 4096 {
+			buf := make([]byte, n, 2*cap(self.pending)+4096)
+			copy(buf, self.pending)
+			self.pending = buf
+		}
+
+		m, err := self.reader.Read(self.pending[n:cap(self.pending)])
+		self.pending = self.pending[:n+m]
+		if m > 0 {
+			self.metrics.BytesRead(m)
 		}
 
-		n, err := self.file.Read(self.text)
 		if err != nil {
+			if err == io.EOF && len(self.pending) == 0 {
+				self.reportProgress(true)
+				return nil, false, io.EOF
+			}
+
+			if timeout, ok := err.(interface{ Timeout() bool }); err != io.EOF && !(ok && timeout.Timeout()) {
+				return nil, false, err
+			}
+		}
+
+		if size, err = self.pendingEventSize(); err != nil {
+			return nil, false, err
+		}
+
+		if len(self.pending) < size {
+			return nil, false, nil
+		}
+	}
+
+	copy(self.head, self.pending)
+	header, err := NewBinLogEventHeader(self.head)
+	if err != nil {
+		return nil, false, err
+	}
+
+	self.pos += BINLOG_EVENT_HEADER_LEN
+	self.checkTimestamp(header)
+	self.resize(header.EventSize - BINLOG_EVENT_HEADER_LEN)
+	copy(self.text, self.pending[BINLOG_EVENT_HEADER_LEN:size])
+	self.pos += int64(len(self.text))
+	self.pending = self.pending[:copy(self.pending, self.pending[size:])]
+	self.reportProgress(false)
+
+	event, err := self.decodeEvent(header)
+	if event, err = self.countEvent(header, event, err); err != nil {
+		return nil, false, err
+	}
+
+	return event, true, nil
+}
+
+// pendingEventSize returns the size of the partially read event, or the
+// header size if the header is not complete yet
+func (self *Parser) pendingEventSize() (int, error) {
+	if len(self.pending) < BINLOG_EVENT_HEADER_LEN {
+		return BINLOG_EVENT_HEADER_LEN, nil
+	}
+
+	size := binary.LittleEndian.Uint32(self.pending[9:])
+	if size < BINLOG_EVENT_HEADER_LEN || size > MAX_EVENT_SIZE {
+		return 0, fmt.Errorf("Invalid event size %d", size)
+	}
+
+	return int(size), nil
+}
+
+// readEvent reads the body of the event and decodes it
+func (self *Parser) readEvent(header *BinLogEventHeader) (BinLogEvent, error) {
+	size := header.EventSize - BINLOG_EVENT_HEADER_LEN
+	self.resize(size)
+	if size != 0 {
+		n, err := self.read(self.text)
+		if err != nil && err != io.ErrUnexpectedEOF {
 			return nil, err
 		}
 
 		if n != len(self.text) {
-			return nil, errors.New("Failed to read event body")
+			return nil, errEventBody
 		}
 	}
 
-	return NewBinLogEvent(header, self.text, self.fde)
+	self.reportProgress(false)
+	return self.decodeEvent(header)
+}
+
+// decodeEvent decodes the event whose body is in self.text
+func (self *Parser) decodeEvent(header *BinLogEventHeader) (BinLogEvent, error) {
+	if !self.fdeChecked {
+		if header.EventType != FORMAT_DESCRIPTION_EVENT && header.EventType != START_EVENT_V3 {
+			if self.strictFDE {
+				return nil, ErrNoFDE
+			}
+
+			self.logger.Warnf("No FormatDescriptionEvent before the event at %d, the events are decoded without checksum",
+				self.pos-int64(header.EventSize))
+		}
+
+		self.fdeChecked = true
+	}
+
+	event, err := NewBinLogEvent(header, self.text, self.eventFde(header))
+	if err != nil {
+		return nil, err
+	}
+
+	switch e := event.(type) {
+	case *FormatDescriptionEvent:
+		if self.relayLog {
+			if self.relayFde == nil {
+				self.relayFde = e
+			} else {
+				self.masterFde = e
+			}
+		}
+	case *PreviousGtidsLogEvent:
+		self.executed.Merge(e.GTIDSet())
+	case *GtidLogEvent:
+		if header.EventType == GTID_LOG_EVENT {
+			self.executed.Add(e.Sid, uint64(e.Gno))
+		}
+
+		self.txnEnd = 0
+		if e.TransactionLength != 0 {
+			self.txnEnd = self.pos - int64(header.EventSize) + int64(e.TransactionLength)
+		}
+	case *QueryEvent:
+		e.redact = self.redact
+	case *RowsQueryEvent:
+		e.redact = self.redact
+	case *UserVarEvent:
+		e.redact = self.redact
+	case *TableMapEvent:
+		self.trackTableMap(e)
+	case *RowsEvent:
+		e.redact = self.redact
+		e.decodeRows(self.tableMaps[e.tableId])
+		if e.flags&STMT_END_F != 0 {
+			self.stmtEnd = true
+		}
+	}
+
+	self.last = header
+	self.lastRead = true
+	return event, nil
+}
+
+// RawEvent returns a copy of the bytes of the last event read by ReadEvent or
+// ReadEventNonBlocking, from the common header to the checksum, or nil if the
+// last event is skipped or after a seek
+func (self *Parser) RawEvent() []byte {
+	if !self.lastRead {
+		return nil
+	}
+
+	raw := make([]byte, 0, len(self.head)+len(self.text))
+	return append(append(raw, self.head...), self.text...)
+}
+
+// ExecutedGtidSet returns the GTIDs executed up to the last event read, i.e.
+// the PREVIOUS_GTIDS of the binlog plus the GTID events read so far. The
+// events skipped by SkipEvent or SeekToPos are not counted.
+func (self *Parser) ExecutedGtidSet() GTIDSet {
+	return self.executed.Clone()
+}
+
+// TableName returns the database and the table of a table id from the table
+// maps of the current statement, ok is false if the table id is not mapped
+func (self *Parser) TableName(tableID uint64) (db, table string, ok bool) {
+	if tableMap := self.tableMaps[tableID]; tableMap != nil {
+		return tableMap.Schema, tableMap.Table, true
+	}
+
+	return "", "", false
+}
+
+// The table maps of a statement precede its rows events, the last of which
+// has STMT_END_F set, then the table ids may be reused by the next statement.
+func (self *Parser) trackTableMap(event *TableMapEvent) {
+	if self.tableMaps == nil || self.stmtEnd {
+		self.tableMaps = make(map[uint64]*TableMapEvent)
+		self.stmtEnd = false
+	}
+
+	self.tableMaps[event.TableId] = event
 }
 
 func (self *Parser) SkipEvent() error {
@@ -72,34 +541,351 @@ func (self *Parser) SkipEvent() error {
 		return err
 	}
 
-	size := header.EventSize - BINLOG_EVENT_HEADER_LEN
+	return self.skipBody(header)
+}
+
+// skipBody skips the body of the event after its header is read
+func (self *Parser) skipBody(header *BinLogEventHeader) error {
+	var err error
+	size := int64(header.EventSize - BINLOG_EVENT_HEADER_LEN)
 	if size != 0 {
-		if _, err = self.file.Seek(int64(size), 1); err != nil {
+		if seeker, ok := self.reader.(io.Seeker); ok {
+			_, err = seeker.Seek(size, io.SeekCurrent)
+		} else {
+			_, err = io.CopyN(ioutil.Discard, self.reader, size)
+		}
+
+		if err != nil {
 			return err
 		}
+
+		self.pos += size
 	}
 
+	self.last = header
+	self.lastRead = false
+	self.reportProgress(false)
 	return nil
 }
 
-func NewParser(file *os.File) (*Parser, error) {
-	text := make([]byte, 4, 1024)
-	n, err := file.Read(text)
-	if err != nil {
+// SkipTransaction skips the rest of the transaction after its GTID event is
+// read, or before its first event is read. If the GTID event has the
+// transaction_length of MySQL 8.0.2+, the transaction is skipped with a single
+// seek if the reader is an io.Seeker. Otherwise the events are read until the
+// XID or COMMIT which terminates the transaction, see TransactionTracker, or
+// the end of the binlog. The event read after an unterminated transaction,
+// e.g. ROTATE_EVENT, is read again by the next ReadEvent if the reader is an
+// io.Seeker, otherwise it is skipped too.
+func (self *Parser) SkipTransaction() error {
+	if self.last != nil && (self.last.EventType == GTID_LOG_EVENT || self.last.EventType == ANONYMOUS_GTID_LOG_EVENT) &&
+		self.txnEnd > self.pos {
+		self.stmtEnd = true
+		if seeker, ok := self.reader.(io.Seeker); ok {
+			return self.seek(seeker, self.txnEnd)
+		}
+
+		n, err := io.CopyN(ioutil.Discard, self.reader, self.txnEnd-self.pos)
+		self.pos += n
+		self.last = nil
+		return err
+	}
+
+	var tracker TransactionTracker
+	for started := false; ; started = true {
+		pos := self.pos
+		event, err := self.ReadEvent()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		state, end := tracker.Track(event)
+		if state == TXN_NONE || state == TXN_BEGIN && started {
+			// the event is not part of the transaction, it is read again if
+			// the reader can seek
+			if seeker, ok := self.reader.(io.Seeker); ok {
+				return self.seek(seeker, pos)
+			}
+
+			return nil
+		}
+
+		if end {
+			return nil
+		}
+	}
+}
+
+// WasClosedCleanly reads the rest of the binlog and reports whether it ends
+// with a STOP_EVENT or a ROTATE_EVENT which is not artificial, as written when
+// the server shuts down or rotates the binlog. A binlog cut off by a crash
+// ends without one, possibly in the middle of an event, which is not reported
+// as an error. The LOG_EVENT_BINLOG_IN_USE_F flag of the FDE alone can not
+// tell a crashed binlog from one which is still being written or from a
+// truncated copy, the server clears it only after writing the final event.
+func (self *Parser) WasClosedCleanly() (bool, error) {
+	for {
+		_, err := self.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+
+		if err == errEventHeader || err == errEventBody {
+			return false, nil
+		}
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if self.last == nil {
+		return false, nil
+	}
+
+	switch self.last.EventType {
+	case STOP_EVENT:
+		return true, nil
+	case ROTATE_EVENT:
+		return self.last.Flags&LOG_EVENT_ARTIFICIAL_F == 0, nil
+	}
+
+	return false, nil
+}
+
+// SeekToPos moves the parser to the event at offset pos of the binlog, the
+// reader must be an io.Seeker. The FormatDescriptionEvent is read first if it
+// is not read yet, since it decides how the following events are decoded,
+// but in the strict FDE mode ErrNoFDE is returned instead unless pos is the
+// FDE. An error is returned if pos is not the start of an event.
+func (self *Parser) SeekToPos(pos int64) error {
+	seeker, ok := self.reader.(io.Seeker)
+	if !ok {
+		return errors.New("Seek is not supported by the reader")
+	}
+
+	if self.fde == nil {
+		if self.strictFDE && pos != 4 {
+			return ErrNoFDE
+		}
+
+		if _, err := self.ReadEvent(); err != nil {
+			return err
+		}
+	}
+
+	ok, err := self.isEventBoundary(seeker, pos)
+	if err == io.EOF {
+		// the end of the binlog
+		return self.seek(seeker, pos)
+	} else if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("Position %d is not at an event boundary", pos)
+	}
+
+	return self.seek(seeker, pos)
+}
+
+// isEventBoundary reports whether an event starts at offset pos, it returns
+// io.EOF at the end of the binlog. The reader is left after the header, or
+// after the event if it is checked by its checksum.
+func (self *Parser) isEventBoundary(seeker io.Seeker, pos int64) (bool, error) {
+	if err := self.seek(seeker, pos); err != nil {
+		return false, err
+	}
+
+	_, err := self.readEventHeader()
+	if err == io.EOF {
+		return false, err
+	}
+
+	if err != nil || pos < 4 || !self.isPlausibleHeader(self.head, pos) {
+		return false, nil
+	}
+
+	if self.fde.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
+		self.resize(binary.LittleEndian.Uint32(self.head[9:]) - BINLOG_EVENT_HEADER_LEN)
+		if _, err = self.read(self.text); err != nil || !self.hasChecksum() {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// isPlausibleHeader reports whether head may be the header of an event at
+// offset pos by its event_size and log_pos, which is the end of the event
+// except in a relay log or the binlogs older than MySQL 4.0
+func (self *Parser) isPlausibleHeader(head []byte, pos int64) bool {
+	size := binary.LittleEndian.Uint32(head[9:])
+	logPos := binary.LittleEndian.Uint32(head[13:])
+	return size >= BINLOG_EVENT_HEADER_LEN && size <= MAX_EVENT_SIZE &&
+		(self.relayLog || logPos == 0 || int64(logPos) == pos+int64(size))
+}
+
+func (self *Parser) seek(seeker io.Seeker, pos int64) error {
+	if _, err := seeker.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+
+	self.pos = pos
+	self.lastTimestamp = 0
+	self.last = nil
+	self.lastRead = false
+	return nil
+}
+
+// ErrorPolicy decides what ReadEvent does when an event can not be decoded
+type ErrorPolicy uint8
+
+const (
+	STOP_ON_ERROR ErrorPolicy = iota // return the error, the default
+	SKIP_CORRUPT                     // skip the event and read the next one
+)
+
+// SetErrorPolicy sets what ReadEvent does when an event can not be decoded.
+// With SKIP_CORRUPT the event is skipped by its event_size, or if its header
+// is corrupt too, the parser resyncs at its log_pos or the next offset which
+// looks like an event, which needs an io.Seeker. The skipped bytes are
+// reported to the Logger. A truncated binlog and the read errors still stop
+// the parsing.
+func (self *Parser) SetErrorPolicy(policy ErrorPolicy) {
+	self.errorPolicy = policy
+}
+
+// skipCorrupt moves the parser to the event after the corrupt one at offset
+// start, header is nil if the header is corrupt too. A header whose log_pos
+// does not match its event_size is taken as corrupt, or the parser would go
+// on from the middle of an event.
+func (self *Parser) skipCorrupt(start int64, header *BinLogEventHeader, cause error) error {
+	if header == nil || !self.isPlausibleHeader(self.head, start) {
+		seeker, ok := self.reader.(io.Seeker)
+		if !ok {
+			return cause
+		}
+
+		next, err := self.findEvent(seeker, start)
+		if err == io.EOF {
+			self.logger.Warnf("Skipped the corrupt bytes from %d to the end of the binlog: %v", start, cause)
+			return io.EOF
+		} else if err != nil {
+			return err
+		}
+
+		if err = self.seek(seeker, next); err != nil {
+			return err
+		}
+	}
+
+	self.logger.Warnf("Skipped the corrupt bytes from %d to %d: %v", start, self.pos, cause)
+	self.last = nil
+	self.lastRead = false
+	return nil
+}
+
+// findEvent returns the offset of the first event after the corrupt header at
+// offset start, which is its log_pos if an event starts there, otherwise the
+// binlog is scanned byte by byte. It returns io.EOF if there is none.
+func (self *Parser) findEvent(seeker io.Seeker, start int64) (int64, error) {
+	if logPos := int64(binary.LittleEndian.Uint32(self.head[13:])); !self.relayLog && logPos > start+BINLOG_EVENT_HEADER_LEN {
+		if ok, err := self.isEventBoundary(seeker, logPos); ok {
+			return logPos, nil
+		} else if err != nil && err != io.EOF {
+			return 0, err
+		}
+	}
+
+	buf := make([]byte, 64*1024)
+	for from := start + 1; ; {
+		if err := self.seek(seeker, from); err != nil {
+			return 0, err
+		}
+
+		n, err := io.ReadFull(self.reader, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+
+		for i := 0; i+BINLOG_EVENT_HEADER_LEN <= n; i++ {
+			pos := from + int64(i)
+			if !self.isPlausibleHeader(buf[i:], pos) {
+				continue
+			}
+
+			if ok, err := self.isEventBoundary(seeker, pos); ok {
+				return pos, nil
+			} else if err != nil && err != io.EOF {
+				return 0, err
+			}
+		}
+
+		if n < len(buf) {
+			return 0, io.EOF
+		}
+
+		from += int64(n - BINLOG_EVENT_HEADER_LEN + 1)
+	}
+}
+
+// SetStrictFDE makes the parser fail with ErrNoFDE if the first event read is
+// not the FormatDescriptionEvent, or START_EVENT_V3 of the old binlogs, and
+// SeekToPos fail before the FDE is read. Otherwise such events are decoded
+// with the default FDE, i.e. without checksum, and a warning is logged.
+func (self *Parser) SetStrictFDE(strict bool) {
+	self.strictFDE = strict
+}
+
+// NewParser returns a parser for a binlog, it fails with ErrEmptyFile or
+// ErrNoEvents if the binlog is empty.
+func NewParser(reader io.Reader) (*Parser, error) {
+	text := make([]byte, 4, INITIAL_BUFFER_SIZE)
+	n, err := io.ReadFull(reader, text)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return nil, err
 	}
 
+	if !bytes.Equal(text[:n], binlogMagic[:n]) {
+		return nil, errors.New("Invalid binlog file header")
+	}
+
 	if n != 4 {
-		return nil, errors.New("Failed to read binlog file header")
+		return nil, ErrEmptyFile
 	}
 
-	if text[0] != 0xfe || text[1] != 'b' || text[2] != 'i' || text[3] != 'n' {
-		return nil, errors.New("Invalid binlog file header")
+	// only a regular file is known to have no more events, a stream or a
+	// binlog being followed may get the events later
+	if file, ok := reader.(*os.File); ok {
+		if info, err := file.Stat(); err == nil && info.Mode().IsRegular() && info.Size() == 4 {
+			return nil, ErrNoEvents
+		}
 	}
 
 	parser := new(Parser)
-	parser.file = file
+	parser.reader = reader
+	parser.pos = 4
+	parser.head = make([]byte, BINLOG_EVENT_HEADER_LEN)
 	parser.text = text
 	parser.fde = nil
+	parser.metrics = nopMetrics{}
+	parser.logger = nopLogger{}
+	parser.executed = make(GTIDSet)
+	return parser, nil
+}
+
+// NewRelayLogParser returns a parser for a relay log, which contains both the
+// relay log's FormatDescriptionEvent and the ones received from the master.
+func NewRelayLogParser(reader io.Reader) (*Parser, error) {
+	parser, err := NewParser(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	parser.relayLog = true
 	return parser, nil
 }
@@ -0,0 +1,113 @@
+//
+// parser_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// the events must not alias the buffer of the parser, which is reused by the
+// next read
+func TestEventsKeepTheirBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+	}{
+		{"queries", fde80 + gtid80 + query80 + queryStatusVars},
+		{"rows", fde57 + tableMapJS + rowsJS},
+		{"integers", fde80 + rowsInts},
+	}
+
+	for _, test := range tests {
+		parser, err := NewParser(bytes.NewReader(unhex(t, test.dump)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var events []BinLogEvent
+		var texts [][]byte
+		for {
+			event, err := parser.ReadEvent()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+
+			text, err := MarshalEventNDJSON(event, 0)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+
+			events = append(events, event)
+			texts = append(texts, text)
+		}
+
+		for i, event := range events {
+			text, err := MarshalEventNDJSON(event, 0)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+
+			if !bytes.Equal(text, texts[i]) {
+				t.Errorf("%s: event %d changed after the next reads\ngot  %s\nwant %s", test.name, i, text, texts[i])
+			}
+		}
+	}
+}
+
+func TestNewParserEmptyFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"0 bytes", []byte{}, ErrEmptyFile},
+		{"3 bytes", []byte{0xfe, 'b', 'i'}, ErrEmptyFile},
+		{"magic only", []byte{0xfe, 'b', 'i', 'n'}, ErrNoEvents},
+	}
+
+	dir := t.TempDir()
+	for _, test := range tests {
+		path := filepath.Join(dir, "mysql-bin.000001")
+		if err := ioutil.WriteFile(path, test.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = NewParser(file); err != test.err {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+
+		file.Close()
+	}
+
+	// a stream may get the events later, and a file which is not a binlog
+	// is not empty
+	parser, err := NewParser(bytes.NewReader([]byte{0xfe, 'b', 'i', 'n'}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = parser.ReadEvent(); err != io.EOF {
+		t.Errorf("magic only stream: got %v, want EOF", err)
+	}
+
+	if _, err = NewParser(bytes.NewReader([]byte{'b', 'i', 'n'})); err == nil || err == ErrEmptyFile {
+		t.Errorf("3 bytes of garbage: got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+//
+// proto.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Protobuf encoding of the events, see binlog.proto for the schema
+//
+
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// protobuf wire types
+const (
+	PROTO_VARINT = 0
+	PROTO_I64    = 1
+	PROTO_LEN    = 2
+)
+
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+
+	return append(buf, byte(v))
+}
+
+func appendTag(buf []byte, field int, wireType int) []byte {
+	return appendVarint(buf, uint64(field)<<3|uint64(wireType))
+}
+
+// the zero values are omitted like proto3
+func appendUintField(buf []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return buf
+	}
+
+	return appendVarint(appendTag(buf, field, PROTO_VARINT), v)
+}
+
+func appendBytesField(buf []byte, field int, v []byte) []byte {
+	buf = appendVarint(appendTag(buf, field, PROTO_LEN), uint64(len(v)))
+	return append(buf, v...)
+}
+
+func appendStringField(buf []byte, field int, v string) []byte {
+	if v == "" {
+		return buf
+	}
+
+	return appendBytesField(buf, field, []byte(v))
+}
+
+func marshalHeaderProto(header *BinLogEventHeader) []byte {
+	var buf []byte
+	buf = appendUintField(buf, 1, uint64(header.Timestamp))
+	buf = appendUintField(buf, 2, uint64(header.EventType))
+	buf = appendUintField(buf, 3, uint64(header.ServerId))
+	buf = appendUintField(buf, 4, uint64(header.EventSize))
+	buf = appendUintField(buf, 5, uint64(header.LogPos))
+	return appendUintField(buf, 6, uint64(header.Flags))
+}
+
+func marshalQueryProto(event *QueryEvent) []byte {
+	var buf []byte
+	buf = appendStringField(buf, 1, event.Schema())
+	buf = appendStringField(buf, 2, event.query())
+	buf = appendUintField(buf, 3, uint64(event.postHeader.ErrorCode))
+	return appendUintField(buf, 4, uint64(event.postHeader.ExecutionTime))
+}
+
+func marshalValueProto(val Any, null bool) []byte {
+	var buf []byte
+	if null {
+		return appendUintField(buf, 1, 1)
+	}
+
+	switch v := val.(type) {
+	case nil:
+		return buf
+	case int64:
+		buf = appendTag(buf, 2, PROTO_VARINT)
+		return appendVarint(buf, uint64(v<<1)^uint64(v>>63))
+	case uint64:
+		buf = appendTag(buf, 3, PROTO_VARINT)
+		return appendVarint(buf, v)
+	case string:
+		return appendBytesField(buf, 4, []byte(v))
+	case []byte:
+		return appendBytesField(buf, 5, v)
+	case float32:
+		return marshalValueProto(float64(v), false)
+	case float64:
+		var bits [8]byte
+		binary.LittleEndian.PutUint64(bits[:], math.Float64bits(v))
+		return append(appendTag(buf, 6, PROTO_I64), bits[:]...)
+	case time.Time:
+		return appendBytesField(buf, 4, []byte(v.Format("2006-01-02 15:04:05.999999")))
+	default:
+		return appendBytesField(buf, 4, []byte(fmt.Sprint(v)))
+	}
+}
+
+func marshalRowsProto(event *RowsEvent) []byte {
+	var buf []byte
+	if event.tableMap == nil {
+		return buf
+	}
+
+	buf = appendStringField(buf, 1, event.tableMap.Schema)
+	buf = appendStringField(buf, 2, event.tableMap.Table)
+	for i := 0; i < event.columnCount; i++ {
+		buf = appendBytesField(buf, 3, []byte(event.tableMap.ColumnName(i)))
+	}
+
+	for _, row := range event.Rows() {
+		var rowBuf []byte
+		for i := range row.Values {
+			rowBuf = appendBytesField(rowBuf, 1, marshalValueProto(event.columnValue(row.Values, i), row.Nulls[i]))
+		}
+
+		buf = appendBytesField(buf, 4, rowBuf)
+	}
+
+	return buf
+}
+
+// MarshalEventProto encodes the event as the Event message of binlog.proto,
+// the query and the rows are set for QUERY_EVENT and the rows events. They are
+// redacted like the payload if the parser is told to.
+func MarshalEventProto(e BinLogEvent) ([]byte, error) {
+	var buf []byte
+	buf = appendBytesField(buf, 1, marshalHeaderProto(e.Header()))
+	for _, line := range e.GetPostHeader() {
+		buf = appendBytesField(buf, 2, []byte(line))
+	}
+
+	for _, line := range e.GetPayload() {
+		buf = appendBytesField(buf, 3, []byte(line))
+	}
+
+	switch event := e.(type) {
+	case *QueryEvent:
+		buf = appendBytesField(buf, 4, marshalQueryProto(event))
+	case *RowsEvent:
+		buf = appendBytesField(buf, 5, marshalRowsProto(event))
+	}
+
+	return buf, nil
+}
+
+// MarshalEventProtoDelimited encodes the event prefixed with its length as a
+// varint, the framing of a stream of protobuf messages
+func MarshalEventProtoDelimited(e BinLogEvent) ([]byte, error) {
+	msg, err := MarshalEventProto(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(appendVarint(nil, uint64(len(msg))), msg...), nil
+}
@@ -0,0 +1,131 @@
+//
+// redact.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Redaction of the personal data in the string values, to share binlogs
+// without leaking it
+//
+
+package binlog
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// RedactOptions controls how the string values are redacted, i.e. the values
+// of the character, binary, BLOB/TEXT and JSON columns, the string literals
+// of the queries and the string user variables. A value is replaced with <redacted len=N>, or with a hash
+// <sha256=... len=N> so that the equal values can still be told.
+type RedactOptions struct {
+	Hash bool
+}
+
+func (self *RedactOptions) redact(val []byte) string {
+	if self.Hash {
+		sum := sha256.Sum256(val)
+		return fmt.Sprintf("<sha256=%x len=%d>", sum[:8], len(val))
+	}
+
+	return fmt.Sprintf("<redacted len=%d>", len(val))
+}
+
+func isRedactedType(t ColumnType) bool {
+	switch t {
+	case MYSQL_TYPE_STRING, MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_VARCHAR, MYSQL_TYPE_BLOB,
+		MYSQL_TYPE_TINY_BLOB, MYSQL_TYPE_MEDIUM_BLOB, MYSQL_TYPE_LONG_BLOB, MYSQL_TYPE_JSON,
+		MYSQL_TYPE_TYPED_ARRAY:
+		return true
+	default:
+		return false
+	}
+}
+
+// columnValue returns the value of the i-th column of the row, redacted if
+// the parser is told to
+func (self *RowsEvent) columnValue(row []Any, i int) Any {
+	if self.redact == nil || row[i] == nil || !isRedactedType(self.tableMap.realType(i)) {
+		return row[i]
+	}
+
+	switch v := row[i].(type) {
+	case string:
+		return self.redact.redact([]byte(v))
+	case []byte:
+		return self.redact.redact(v)
+	case *TypedArray:
+		if v.Values == nil {
+			return self.redact.redact(v.Raw)
+		}
+
+		values := make([]Any, len(v.Values))
+		for n, elem := range v.Values {
+			if text, ok := elem.(string); ok {
+				elem = self.redact.redact([]byte(text))
+			}
+			values[n] = elem
+		}
+
+		return &TypedArray{Values: values}
+	default:
+		return v
+	}
+}
+
+// RedactQuery replaces the quoted string literals of the query, the quoted
+// identifiers and the numbers are kept
+func RedactQuery(query string, opts *RedactOptions) string {
+	var sb strings.Builder
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if c == '`' {
+			// a quoted identifier
+			end := strings.IndexByte(query[i+1:], '`')
+			if end < 0 {
+				sb.WriteString(query[i:])
+				break
+			}
+
+			sb.WriteString(query[i : i+end+2])
+			i += end + 1
+			continue
+		}
+
+		if c != '\'' && c != '"' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		// find the closing quote, skipping the escaped and doubled ones
+		var lit []byte
+		j := i + 1
+		for ; j < len(query); j++ {
+			if query[j] == '\\' && j+1 < len(query) {
+				j++
+				lit = append(lit, query[j])
+			} else if query[j] == c && j+1 < len(query) && query[j+1] == c {
+				j++
+				lit = append(lit, c)
+			} else if query[j] == c {
+				break
+			} else {
+				lit = append(lit, query[j])
+			}
+		}
+
+		sb.WriteByte(c)
+		sb.WriteString(opts.redact(lit))
+		sb.WriteByte(c)
+		i = j
+	}
+
+	return sb.String()
+}
+
+// SetRedactOptions makes the parser redact the string values of the events
+// it reads in the output of GetPayload, SQL, MarshalEventProto and the change
+// records, nil turns it off. QueryEvent.Query still returns the original query.
+func (self *Parser) SetRedactOptions(opts *RedactOptions) {
+	self.redact = opts
+}
@@ -0,0 +1,107 @@
+//
+// redact_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// SET @v='secret'; insert into t values (@v, 'alice', "it's", 42) and the
+// row (1, 'alice', 'ssn 123') of `test`.`t` (id INT, name VARCHAR(20),
+// note BLOB)
+const rowsSecret = `
+	# USER_VAR_EVENT at 123
+	80 4c 94 5d 0e 01 00 00 00 2d 00 00 00 a8 00 00 00 00 00 01 00 00 00 76
+	00 00 21 00 00 00 06 00 00 00 73 65 63 72 65 74 00 e5 6f 66 33
+	# QUERY_EVENT at 168
+	80 4c 94 5d 02 01 00 00 00 6c 00 00 00 14 01 00 00 00 00 05 00 00 00 00
+	00 00 00 04 00 00 15 00 00 00 00 00 00 01 00 00 00 40 00 00 00 00 04 21
+	00 21 00 08 00 74 65 73 74 00 69 6e 73 65 72 74 20 69 6e 74 6f 20 74 20
+	76 61 6c 75 65 73 20 28 40 76 2c 20 27 61 6c 69 63 65 27 2c 20 22 69 74
+	27 73 22 2c 20 34 32 29 fd 04 6a 85
+	# TABLE_MAP_EVENT at 276
+	80 4c 94 5d 13 01 00 00 00 31 00 00 00 45 01 00 00 00 00 50 00 00 00 00
+	00 01 00 04 74 65 73 74 00 01 74 00 03 03 0f fc 03 14 00 02 06 c3 d2 00
+	dd
+	# WRITE_ROWS_EVENT at 325
+	80 4c 94 5d 1e 01 00 00 00 37 00 00 00 7c 01 00 00 00 00 50 00 00 00 00
+	00 01 00 02 00 03 07 00 01 00 00 00 05 61 6c 69 63 65 07 00 73 73 6e 20
+	31 32 33 d5 3a e7 42
+	# XID_EVENT at 380
+	80 4c 94 5d 10 01 00 00 00 1f 00 00 00 9b 01 00 00 00 00 09 00 00 00 00
+	00 00 00 c0 f0 94 f4
+`
+
+// readRedactedEvents reads all the events of the binlog in a hex dump with
+// the redact options
+func readRedactedEvents(t *testing.T, dump string, opts *RedactOptions) []BinLogEvent {
+	t.Helper()
+	parser, err := NewParser(bytes.NewReader(unhex(t, dump)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser.SetRedactOptions(opts)
+	var events []BinLogEvent
+	for {
+		event, err := parser.ReadEvent()
+		if err == io.EOF {
+			return events
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		events = append(events, event)
+	}
+}
+
+func TestRedactProto(t *testing.T) {
+	secrets := []string{"secret", "alice", "it's", "ssn 123"}
+	tests := []struct {
+		name string
+		opts *RedactOptions
+		want []string
+	}{
+		{"not redacted", nil, secrets},
+		{"redacted", &RedactOptions{}, []string{"<redacted len=6>", "<redacted len=5>", "<redacted len=4>",
+			"<redacted len=7>"}},
+		{"hashed", &RedactOptions{Hash: true}, []string{"<sha256=2bb80d537b1da3e3 len=6>",
+			"<sha256=2bd806c97f0e00af len=5>", "len=4>", "len=7>"}},
+	}
+
+	for _, test := range tests {
+		events := readRedactedEvents(t, fde57+rowsSecret, test.opts)
+		var msg []byte
+		for _, event := range events[1:5] {
+			buf, err := MarshalEventProto(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg = append(msg, buf...)
+		}
+
+		for _, want := range test.want {
+			if !bytes.Contains(msg, []byte(want)) {
+				t.Errorf("%s: no %q", test.name, want)
+			}
+		}
+
+		if test.opts == nil {
+			continue
+		}
+
+		for _, secret := range secrets {
+			if bytes.Contains(msg, []byte(secret)) {
+				t.Errorf("%s: %q is not redacted", test.name, secret)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+//
+// registry.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"sync"
+)
+
+// EventDecoder decodes the event body text, which excludes the common header
+// and is only valid during the call, see NewBinLogEvent.
+type EventDecoder func(header *BinLogEventHeader, text []byte, fde *FormatDescriptionEvent) (BinLogEvent, error)
+
+var (
+	decodersLock sync.RWMutex
+	decoders     = make(map[LogEventType]EventDecoder)
+)
+
+// RegisterEventDecoder registers the decoder of an event type which the
+// package does not decode, e.g. a proprietary event of a MySQL fork. The
+// built-in decoders take precedence, so registering one of the decoded types
+// has no effect. A nil fn removes the decoder.
+func RegisterEventDecoder(t LogEventType, fn func(*BinLogEventHeader, []byte, *FormatDescriptionEvent) (BinLogEvent, error)) {
+	decodersLock.Lock()
+	defer decodersLock.Unlock()
+	if fn == nil {
+		delete(decoders, t)
+	} else {
+		decoders[t] = fn
+	}
+}
+
+func lookupEventDecoder(t LogEventType) EventDecoder {
+	decodersLock.RLock()
+	defer decodersLock.RUnlock()
+	return decoders[t]
+}
@@ -0,0 +1,683 @@
+//
+// rows.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// TABLE_MAP_EVENT and the row events of row based replication
+//
+
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// row event flags
+const (
+	STMT_END_F              = 0x0001
+	NO_FOREIGN_KEY_CHECKS_F = 0x0002
+	RELAXED_UNIQUE_CHECKS_F = 0x0004
+	COMPLETE_ROWS_F         = 0x0008
+)
+
+// length of table_id and flags in the post header
+const ROWS_EVENT_TABLE_ID_LEN = 6
+
+// type codes of the extra row info in the extra data of version 2 events
+const (
+	ROWS_EXTRA_INFO_NDB  = 0
+	ROWS_EXTRA_INFO_PART = 1
+)
+
+func isRowsEvent(t LogEventType) bool {
+	switch t {
+	case WRITE_ROWS_EVENT_V1, UPDATE_ROWS_EVENT_V1, DELETE_ROWS_EVENT_V1,
+		WRITE_ROWS_EVENT, UPDATE_ROWS_EVENT, DELETE_ROWS_EVENT:
+		return true
+	default:
+		return false
+	}
+}
+
+func isUpdateRowsEvent(t LogEventType) bool {
+	return t == UPDATE_ROWS_EVENT_V1 || t == UPDATE_ROWS_EVENT
+}
+
+func bitSet(bitmap []byte, i int) bool {
+	return bitmap[i/8]&(1<<uint(i%8)) != 0
+}
+
+func bitCount(bitmap []byte, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		if bitSet(bitmap, i) {
+			count++
+		}
+	}
+
+	return count
+}
+
+type TableMapEvent struct {
+	header      *BinLogEventHeader
+	TableId     uint64
+	Flags       uint16
+	Schema      string
+	Table       string
+	ColumnTypes []ColumnType
+	ColumnMeta  []uint16
+	NullBitmap  []byte         // bit set if the column is nullable
+	Metadata    *TableMetadata // nil if there is no optional metadata
+}
+
+func (self *TableMapEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *TableMapEvent) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *TableMapEvent) GetPostHeader() []string {
+	return []string{
+		fmt.Sprintf("table_id: %d", self.TableId),
+		fmt.Sprintf("flags: %d", self.Flags),
+	}
+}
+
+func (self *TableMapEvent) GetPayload() []string {
+	val := []string{
+		fmt.Sprintf("schema: %s", self.Schema),
+		fmt.Sprintf("table: %s", self.Table),
+		fmt.Sprintf("column_count: %d", len(self.ColumnTypes)),
+		"columns:",
+	}
+
+	for i, t := range self.ColumnTypes {
+		name := t.String()
+		if t == MYSQL_TYPE_TYPED_ARRAY {
+			name = fmt.Sprintf("%v(%v)", t, ColumnType(self.ColumnMeta[i]))
+		}
+
+		val = append(val, fmt.Sprintf("	%s: %s, meta: %d, nullable: %v%s", self.ColumnName(i),
+			name, self.ColumnMeta[i], self.IsNullable(i), self.formatMetadata(i)))
+	}
+
+	if self.Metadata != nil && self.Metadata.PrimaryKey != nil {
+		var pk []string
+		for n, col := range self.Metadata.PrimaryKey {
+			if prefix := self.Metadata.PrimaryKeyPrefix[n]; prefix != 0 {
+				pk = append(pk, fmt.Sprintf("%s(%d)", self.ColumnName(col), prefix))
+			} else {
+				pk = append(pk, self.ColumnName(col))
+			}
+		}
+
+		val = append(val, fmt.Sprintf("primary_key: %s", strings.Join(pk, ", ")))
+	}
+
+	return val
+}
+
+// ColumnName returns the name of the i-th column, which is @N (1-based) like
+// mysqlbinlog if the name is not in the optional metadata
+func (self *TableMapEvent) ColumnName(i int) string {
+	if self.Metadata != nil && self.Metadata.ColumnNames != nil {
+		return self.Metadata.ColumnNames[i]
+	}
+
+	return fmt.Sprintf("@%d", i+1)
+}
+
+// IsNullable reports whether the i-th column is nullable by the null bitmap
+// at the end of the event. Note that the NULL bitmap of a row image is not
+// aligned to the nullable columns, it has a bit for every column in the image.
+func (self *TableMapEvent) IsNullable(i int) bool {
+	return bitSet(self.NullBitmap, i)
+}
+
+// tableIdLen returns the length of the table id, which is 4 bytes in the
+// binlogs of MySQL 5.1.0 to 5.1.15 whose post header is 6 bytes
+func tableIdLen(fde *FormatDescriptionEvent, t LogEventType) int {
+	if fde.PostHeaderLen(t, ROWS_EVENT_TABLE_ID_LEN+2) == 4+2 {
+		return 4
+	}
+
+	return ROWS_EVENT_TABLE_ID_LEN
+}
+
+func readCString(r *bytes.Reader) (string, error) {
+	length, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	// the name is followed by a 0 byte
+	buf := make([]byte, int(length)+1)
+	if _, err = readFull(r, buf); err != nil {
+		return "", err
+	}
+
+	return string(buf[:length]), nil
+}
+
+func newTableMapEvent(header *BinLogEventHeader, text []byte,
+	fde *FormatDescriptionEvent) (*TableMapEvent, error) {
+
+	end := len(text)
+	if fde.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
+		end -= BINLOG_CHECKSUM_LEN
+	}
+
+	idLen := tableIdLen(fde, header.EventType)
+	if end < idLen+2 {
+		return nil, errors.New("Invalid TableMapEvent len")
+	}
+
+	r := bytes.NewReader(text[:end])
+	event := new(TableMapEvent)
+	event.header = header
+	var err error
+	if event.TableId, err = readUintLE(r, idLen); err != nil {
+		return nil, err
+	}
+
+	if err = binary.Read(r, binary.LittleEndian, &event.Flags); err != nil {
+		return nil, err
+	}
+
+	if event.Schema, err = readCString(r); err != nil {
+		return nil, err
+	}
+
+	if event.Table, err = readCString(r); err != nil {
+		return nil, err
+	}
+
+	count, err := readPackedInt(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if count > uint64(r.Len()) {
+		return nil, errors.New("Invalid TableMapEvent column count")
+	}
+
+	types := make([]byte, count)
+	if _, err = readFull(r, types); err != nil {
+		return nil, err
+	}
+
+	event.ColumnTypes = make([]ColumnType, count)
+	for i, t := range types {
+		event.ColumnTypes[i] = ColumnType(t)
+	}
+
+	metaLen, err := readPackedInt(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if metaLen > uint64(r.Len()) {
+		return nil, errors.New("Invalid TableMapEvent metadata len")
+	}
+
+	meta := make([]byte, metaLen)
+	if _, err = readFull(r, meta); err != nil {
+		return nil, err
+	}
+
+	if event.ColumnMeta, err = readColumnMeta(event.ColumnTypes, meta); err != nil {
+		return nil, err
+	}
+
+	event.NullBitmap = make([]byte, (count+7)/8)
+	if _, err = readFull(r, event.NullBitmap); err != nil {
+		return nil, err
+	}
+
+	if r.Len() > 0 {
+		optional := make([]byte, r.Len())
+		readFull(r, optional)
+		if err = event.parseOptionalMetadata(optional); err != nil {
+			return nil, err
+		}
+	}
+
+	return event, nil
+}
+
+// WRITE_ROWS_EVENT, UPDATE_ROWS_EVENT and DELETE_ROWS_EVENT, version 1 and 2
+type RowsEvent struct {
+	header       *BinLogEventHeader
+	tableId      uint64
+	flags        uint16
+	extraData    []byte // version 2 only
+	ndbFormat    uint8
+	ndbData      []byte
+	partitionId  int // -1 if absent
+	sourcePartId int // partition of the before image of update, -1 if absent
+	columnCount  int
+	columns      []byte // bitmap of the columns in the (before) image
+	columnsAfter []byte // bitmap of the columns in the after image of update
+	rowsData     []byte // the raw row images
+
+	// decoded with the table map, an update event holds the before and the
+	// after image in turn, the value of NULL or a column not in the image is nil
+	tableMap *TableMapEvent
+	rows     [][]Any
+	err      error
+	redact   *RedactOptions
+}
+
+func (self *RowsEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *RowsEvent) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *RowsEvent) GetPostHeader() []string {
+	val := []string{
+		fmt.Sprintf("table_id: %d", self.tableId),
+		fmt.Sprintf("flags: %d", self.flags),
+	}
+
+	if self.extraData != nil {
+		val = append(val, fmt.Sprintf("extra_data_length: %d", len(self.extraData)+2))
+	}
+
+	if self.ndbData != nil {
+		val = append(val, fmt.Sprintf("ndb_info_format: %d", self.ndbFormat))
+		val = append(val, fmt.Sprintf("ndb_info: %s", hex.EncodeToString(self.ndbData)))
+	}
+
+	if self.partitionId >= 0 {
+		val = append(val, fmt.Sprintf("partition_id: %d", self.partitionId))
+	}
+
+	if self.sourcePartId >= 0 {
+		val = append(val, fmt.Sprintf("source_partition_id: %d", self.sourcePartId))
+	}
+
+	return val
+}
+
+func formatBitmap(bitmap []byte, n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		if bitSet(bitmap, i) {
+			sb.WriteByte('1')
+		} else {
+			sb.WriteByte('0')
+		}
+	}
+
+	return sb.String()
+}
+
+// formatRow formats the columns in the row image, the other columns are
+// skipped, or labeled as unchanged for the after image of an update which
+// is partial with binlog_row_image=MINIMAL
+func (self *RowsEvent) formatRow(row []Any, columns []byte, after bool) string {
+	var val []string
+	for i := 0; i < self.columnCount; i++ {
+		if !bitSet(columns, i) {
+			if after {
+				val = append(val, fmt.Sprintf("%s=(unchanged)", self.tableMap.ColumnName(i)))
+			}
+
+			continue
+		}
+
+		val = append(val, fmt.Sprintf("%s=%s", self.tableMap.ColumnName(i),
+			formatValue(self.columnValue(row, i), self.tableMap.ColumnTypes[i], self.tableMap.ColumnMeta[i])))
+	}
+
+	return strings.Join(val, " ")
+}
+
+func (self *RowsEvent) GetPayload() []string {
+	val := []string{}
+	if self.tableMap != nil {
+		val = append(val, fmt.Sprintf("table: `%s`.`%s`", self.tableMap.Schema, self.tableMap.Table))
+	}
+
+	val = append(val, fmt.Sprintf("column_count: %d", self.columnCount))
+	val = append(val, fmt.Sprintf("columns: %s", formatBitmap(self.columns, self.columnCount)))
+	if self.columnsAfter != nil {
+		val = append(val, fmt.Sprintf("columns_after: %s", formatBitmap(self.columnsAfter, self.columnCount)))
+	}
+
+	if self.err != nil {
+		val = append(val, fmt.Sprintf("error: %v", self.err))
+	}
+
+	if self.tableMap == nil {
+		return append(val, self.rawRows()...)
+	}
+
+	if self.err != nil {
+		val = append(val, fmt.Sprintf("rows:\n%s", strings.TrimRight(hex.Dump(self.rowsData), "\n")))
+		return val
+	}
+
+	val = append(val, "rows:")
+	update := isUpdateRowsEvent(self.header.EventType)
+	for i, row := range self.rows {
+		if !update {
+			val = append(val, fmt.Sprintf("	[%d] %s", i, self.formatRow(row, self.columns, false)))
+		} else if i%2 == 0 {
+			val = append(val, fmt.Sprintf("	[%d] before: %s", i/2, self.formatRow(row, self.columns, false)))
+		} else {
+			val = append(val, fmt.Sprintf("	[%d] after: %s", i/2, self.formatRow(row, self.columnsAfter, true)))
+		}
+	}
+
+	return val
+}
+
+// rawRows describes the rows which can not be decoded without the table map,
+// e.g. when reading from a position in the middle of a transaction. The row
+// boundaries depend on the column types, only the null bitmap of the first
+// row, which precedes its values, is known.
+func (self *RowsEvent) rawRows() []string {
+	var val []string
+	n := bitCount(self.columns, self.columnCount)
+	if len(self.rowsData) >= (n+7)/8 {
+		val = append(val, fmt.Sprintf("first_row_nulls: %s", formatBitmap(self.rowsData, n)))
+	}
+
+	return append(val, fmt.Sprintf("rows: no table map, %d bytes raw\n%s", len(self.rowsData),
+		strings.TrimRight(hex.Dump(self.rowsData), "\n")))
+}
+
+// Row is a decoded row image, Values are indexed by column. The value of a
+// column which is NULL or not in the image is nil, Nulls tells them apart.
+type Row struct {
+	Values []Any
+	Nulls  []bool
+}
+
+// Rows returns the decoded rows, an update event returns the before and the
+// after image of each row in turn. It is nil if the rows can not be decoded,
+// see Err.
+func (self *RowsEvent) Rows() []Row {
+	if self.rows == nil {
+		return nil
+	}
+
+	rows := make([]Row, len(self.rows))
+	update := isUpdateRowsEvent(self.header.EventType)
+	for n, values := range self.rows {
+		columns := self.columns
+		if update && n%2 == 1 {
+			columns = self.columnsAfter
+		}
+
+		rows[n].Values = values
+		rows[n].Nulls = make([]bool, self.columnCount)
+		for i := range values {
+			rows[n].Nulls[i] = values[i] == nil && bitSet(columns, i)
+		}
+	}
+
+	return rows
+}
+
+// TableID returns the table id, which refers to the TABLE_MAP_EVENT
+func (self *RowsEvent) TableID() uint64 {
+	return self.tableId
+}
+
+// Flags returns the flags of the event, e.g. STMT_END_F
+func (self *RowsEvent) Flags() uint16 {
+	return self.flags
+}
+
+// TableMap returns the TABLE_MAP_EVENT the rows are decoded with
+func (self *RowsEvent) TableMap() *TableMapEvent {
+	return self.tableMap
+}
+
+// Err returns the error of decoding the rows, e.g. the table map is missing
+func (self *RowsEvent) Err() error {
+	return self.err
+}
+
+func (self *RowsEvent) decodeRow(r *bytes.Reader, columns []byte) ([]Any, error) {
+	// the NULL bitmap has a bit for each column in the image, nullable or not,
+	// see unpack_row in sql/rpl_record.cc
+	nulls := make([]byte, (bitCount(columns, self.columnCount)+7)/8)
+	if _, err := readFull(r, nulls); err != nil {
+		return nil, err
+	}
+
+	row := make([]Any, self.columnCount)
+	n := 0
+	for i := 0; i < self.columnCount; i++ {
+		if !bitSet(columns, i) {
+			continue
+		}
+
+		null := bitSet(nulls, n)
+		n++
+		if null {
+			continue
+		}
+
+		val, err := decodeValue(r, self.tableMap.ColumnTypes[i], self.tableMap.ColumnMeta[i])
+		if err != nil {
+			return nil, fmt.Errorf("column %d: %v", i+1, err)
+		}
+
+		if v, ok := val.(int64); ok && self.tableMap.isUnsigned(i) {
+			val = toUnsigned(v, self.tableMap.ColumnTypes[i])
+		}
+
+		row[i] = self.tableMap.transcode(i, val)
+	}
+
+	return row, nil
+}
+
+// decodeRows decodes the row images with the table map of the event
+func (self *RowsEvent) decodeRows(tableMap *TableMapEvent) {
+	self.tableMap = tableMap
+	self.rows = nil
+	self.err = nil
+	if tableMap == nil {
+		self.err = fmt.Errorf("No table map for table_id %d", self.tableId)
+		return
+	}
+
+	if len(tableMap.ColumnTypes) != self.columnCount {
+		self.err = fmt.Errorf("Column count %d mismatches the table map %d",
+			self.columnCount, len(tableMap.ColumnTypes))
+		return
+	}
+
+	update := isUpdateRowsEvent(self.header.EventType)
+	r := bytes.NewReader(self.rowsData)
+	for r.Len() > 0 {
+		left := r.Len()
+		row, err := self.decodeRow(r, self.columns)
+		if err != nil {
+			self.rows, self.err = nil, err
+			return
+		}
+
+		self.rows = append(self.rows, row)
+		if update {
+			if row, err = self.decodeRow(r, self.columnsAfter); err != nil {
+				self.rows, self.err = nil, err
+				return
+			}
+
+			self.rows = append(self.rows, row)
+		}
+
+		// the rows of no columns would never end
+		if r.Len() == left {
+			self.rows, self.err = nil, errors.New("Invalid RowsEvent of empty row images")
+			return
+		}
+	}
+}
+
+// parseExtraData decodes the extra row info, which is a list of a type code
+// followed by the info. NDB info is its length, which includes the length and
+// the format byte, the format and the data. Partition info is the partition
+// id, followed by the source partition id for update events. Decoding stops
+// at an unknown type code, the extra data is skipped as a whole anyway.
+func (self *RowsEvent) parseExtraData() error {
+	r := bytes.NewReader(self.extraData)
+	for r.Len() > 0 {
+		tag, _ := r.ReadByte()
+		switch tag {
+		case ROWS_EXTRA_INFO_NDB:
+			length, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+
+			if length < 2 {
+				return errors.New("Invalid NDB info len")
+			}
+
+			if self.ndbFormat, err = r.ReadByte(); err != nil {
+				return err
+			}
+
+			self.ndbData = make([]byte, length-2)
+			if _, err = readFull(r, self.ndbData); err != nil {
+				return err
+			}
+		case ROWS_EXTRA_INFO_PART:
+			id, err := readUintLE(r, 2)
+			if err != nil {
+				return err
+			}
+
+			self.partitionId = int(id)
+			if isUpdateRowsEvent(self.header.EventType) {
+				if id, err = readUintLE(r, 2); err != nil {
+					return err
+				}
+
+				self.sourcePartId = int(id)
+			}
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func newRowsEvent(header *BinLogEventHeader, text []byte,
+	fde *FormatDescriptionEvent) (*RowsEvent, error) {
+
+	end := len(text)
+	if fde.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
+		end -= BINLOG_CHECKSUM_LEN
+	}
+
+	idLen := tableIdLen(fde, header.EventType)
+	if end < idLen+2 {
+		return nil, errors.New("Invalid RowsEvent len")
+	}
+
+	r := bytes.NewReader(text[:end])
+	event := new(RowsEvent)
+	event.header = header
+	event.partitionId = -1
+	event.sourcePartId = -1
+	var err error
+	if event.tableId, err = readUintLE(r, idLen); err != nil {
+		return nil, err
+	}
+
+	if err = binary.Read(r, binary.LittleEndian, &event.flags); err != nil {
+		return nil, err
+	}
+
+	if header.EventType >= WRITE_ROWS_EVENT {
+		// the length includes itself
+		var extraLen uint16
+		if err = binary.Read(r, binary.LittleEndian, &extraLen); err != nil {
+			return nil, err
+		}
+
+		if extraLen < 2 {
+			return nil, errors.New("Invalid RowsEvent extra data len")
+		}
+
+		event.extraData = make([]byte, extraLen-2)
+		if _, err = readFull(r, event.extraData); err != nil {
+			return nil, err
+		}
+
+		if err = event.parseExtraData(); err != nil {
+			return nil, err
+		}
+	}
+
+	count, err := readPackedInt(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if (count+7)/8 > uint64(r.Len()) {
+		return nil, errors.New("Invalid RowsEvent column count")
+	}
+
+	event.columnCount = int(count)
+	event.columns = make([]byte, (count+7)/8)
+	if _, err = readFull(r, event.columns); err != nil {
+		return nil, err
+	}
+
+	if isUpdateRowsEvent(header.EventType) {
+		event.columnsAfter = make([]byte, (count+7)/8)
+		if _, err = readFull(r, event.columnsAfter); err != nil {
+			return nil, err
+		}
+	}
+
+	event.rowsData = make([]byte, r.Len())
+	if _, err = readFull(r, event.rowsData); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// ForEachRow reads the rest of the binlog and calls fn for each rows event
+// with the table map it is decoded with, which is nil if the table map is
+// missing, see RowsEvent.Err. The other events are only used to track the
+// table maps of each statement and are not delivered.
+func (self *Parser) ForEachRow(fn func(tm *TableMapEvent, ev BinLogEvent) error) error {
+	for {
+		event, err := self.ReadEvent()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		if rows, ok := event.(*RowsEvent); ok {
+			if err = fn(rows.tableMap, rows); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+//
+// rows_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// the table map of `test`.`ints`, whose columns are TINYINT, SMALLINT,
+// MEDIUMINT, INT and BIGINT UNSIGNED followed by the same types signed, as
+// told by the SIGNEDNESS metadata of MySQL 8.0, and a row of all the bits set
+// and a row of only the sign bits set
+const rowsInts = `
+	# TABLE_MAP_EVENT at 123
+	80 4c 94 5d 13 01 00 00 00 3d 00 00 00 b8 00 00 00 00 00 51 00 00 00 00
+	00 01 00 04 74 65 73 74 00 04 69 6e 74 73 00 0a 01 02 09 03 08 01 02 09
+	03 08 00 00 00 01 02 f8 00 97 70 0b bf
+	# WRITE_ROWS_EVENT at 184
+	80 4c 94 5d 1e 01 00 00 00 70 00 00 00 28 01 00 00 00 00 51 00 00 00 00
+	00 01 00 02 00 0a ff 03 00 00 ff ff ff ff ff ff ff ff ff ff ff ff ff ff
+	ff ff ff ff ff ff ff ff ff ff ff ff ff ff ff ff ff ff ff ff ff ff 00 00
+	80 00 80 00 00 80 00 00 00 80 00 00 00 00 00 00 00 80 80 00 80 00 00 80
+	00 00 00 80 00 00 00 00 00 00 00 80 6c 78 f5 c3
+`
+
+func TestDecodeUnsigned(t *testing.T) {
+	events := readEvents(t, fde80+rowsInts)
+	rows := events[2].(*RowsEvent)
+	if rows.Err() != nil {
+		t.Fatal(rows.Err())
+	}
+
+	tests := []struct {
+		column int
+		max    Any
+		sign   Any
+	}{
+		{0, uint64(255), uint64(128)},
+		{1, uint64(65535), uint64(32768)},
+		{2, uint64(16777215), uint64(8388608)},
+		{3, uint64(4294967295), uint64(2147483648)},
+		{4, uint64(18446744073709551615), uint64(9223372036854775808)},
+		{5, int64(-1), int64(-128)},
+		{6, int64(-1), int64(-32768)},
+		{7, int64(-1), int64(-8388608)},
+		{8, int64(-1), int64(-2147483648)},
+		{9, int64(-1), int64(-9223372036854775808)},
+	}
+
+	decoded := rows.Rows()
+	for _, test := range tests {
+		columnType := rows.tableMap.ColumnTypes[test.column]
+		if got := decoded[0].Values[test.column]; !reflect.DeepEqual(got, test.max) {
+			t.Errorf("%v column %d: got %T %v, want %T %v", columnType, test.column, got, got, test.max, test.max)
+		}
+
+		if got := decoded[1].Values[test.column]; !reflect.DeepEqual(got, test.sign) {
+			t.Errorf("%v column %d: got %T %v, want %T %v", columnType, test.column, got, got, test.sign, test.sign)
+		}
+	}
+}
+
+// the table map of `test`.`p` (id INT, v VARCHAR(10)) and its version 2 rows
+// events with the partition info, the partition info of an update, the NDB
+// info and no extra data
+const rowsExtraData = `
+	# TABLE_MAP_EVENT at 123
+	80 4c 94 5d 13 01 00 00 00 2f 00 00 00 aa 00 00 00 00 00 52 00 00 00 00
+	00 01 00 04 74 65 73 74 00 01 70 00 02 03 0f 02 0a 00 02 16 33 fa 0a
+	# WRITE_ROWS_EVENT at 170
+	80 4c 94 5d 1e 01 00 00 00 2d 00 00 00 d7 00 00 00 00 00 52 00 00 00 00
+	00 01 00 05 00 01 03 00 02 03 00 01 00 00 00 01 61 dc 13 68 a3
+	# UPDATE_ROWS_EVENT at 215
+	80 4c 94 5d 1f 01 00 00 00 37 00 00 00 0e 01 00 00 00 00 52 00 00 00 00
+	00 01 00 07 00 01 02 00 05 00 02 03 03 00 01 00 00 00 01 61 00 01 00 00
+	00 01 62 86 b2 52 bc
+	# DELETE_ROWS_EVENT at 270
+	80 4c 94 5d 20 01 00 00 00 2f 00 00 00 3d 01 00 00 00 00 52 00 00 00 00
+	00 01 00 07 00 00 04 00 ab cd 02 03 00 01 00 00 00 01 62 51 51 98 1c
+	# WRITE_ROWS_EVENT at 317
+	80 4c 94 5d 1e 01 00 00 00 2a 00 00 00 67 01 00 00 00 00 52 00 00 00 00
+	00 01 00 02 00 02 03 00 02 00 00 00 01 63 4a e2 cb 0e
+`
+
+func TestRowsExtraData(t *testing.T) {
+	tests := []struct {
+		extraData    []byte
+		partitionId  int
+		sourcePartId int
+		ndbData      []byte
+		rows         string
+	}{
+		{[]byte{1, 3, 0}, 3, -1, nil, "[[1 a]]"},
+		{[]byte{1, 2, 0, 5, 0}, 2, 5, nil, "[[1 a] [1 b]]"},
+		{[]byte{0, 4, 0, 0xab, 0xcd}, -1, -1, []byte{0xab, 0xcd}, "[[1 b]]"},
+		{[]byte{}, -1, -1, nil, "[[2 c]]"},
+	}
+
+	events := readEvents(t, fde80+rowsExtraData)[2:]
+	for i, test := range tests {
+		event := events[i].(*RowsEvent)
+		if event.Err() != nil {
+			t.Errorf("%v: %v", event.header.EventType, event.Err())
+			continue
+		}
+
+		if !reflect.DeepEqual(event.extraData, test.extraData) || event.partitionId != test.partitionId ||
+			event.sourcePartId != test.sourcePartId || !reflect.DeepEqual(event.ndbData, test.ndbData) {
+			t.Errorf("%v: got extra data %x, partition %d, source partition %d and NDB info %x", event.header.EventType,
+				event.extraData, event.partitionId, event.sourcePartId, event.ndbData)
+		}
+
+		var rows []string
+		for _, row := range event.Rows() {
+			rows = append(rows, fmt.Sprint(row.Values))
+		}
+
+		// a misread extra data shifts the row images
+		if got := fmt.Sprint(rows); got != test.rows {
+			t.Errorf("%v: got rows %s, want %s", event.header.EventType, got, test.rows)
+		}
+	}
+}
+
+// the table map of `test`.`m` (id INT, a VARCHAR(10), b INT, c BIGINT) and its
+// rows events with binlog_row_image=MINIMAL, the before images have only the
+// primary key id, the after images only the changed a and b
+const rowsMinimal = `
+	# TABLE_MAP_EVENT at 123
+	80 4c 94 5d 13 01 00 00 00 31 00 00 00 ac 00 00 00 00 00 53 00 00 00 00
+	00 01 00 04 74 65 73 74 00 01 6d 00 04 03 0f 03 08 02 0a 00 0e 55 9d a4
+	f0
+	# UPDATE_ROWS_EVENT at 172
+	80 4c 94 5d 1f 01 00 00 00 37 00 00 00 e3 00 00 00 00 00 53 00 00 00 00
+	00 01 00 02 00 04 01 06 00 01 00 00 00 02 01 78 00 02 00 00 00 00 00 07
+	00 00 00 03 1e a0 4b
+	# DELETE_ROWS_EVENT at 227
+	80 4c 94 5d 20 01 00 00 00 28 00 00 00 0b 01 00 00 00 00 53 00 00 00 00
+	00 01 00 02 00 04 01 00 03 00 00 00 2c 3a 81 93
+`
+
+func TestMinimalRowImage(t *testing.T) {
+	tests := []struct {
+		rows  []Row
+		lines []string
+	}{
+		{
+			[]Row{
+				{[]Any{int64(1), nil, nil, nil}, []bool{false, false, false, false}},
+				{[]Any{nil, "x", nil, nil}, []bool{false, false, true, false}},
+				{[]Any{int64(2), nil, nil, nil}, []bool{false, false, false, false}},
+				{[]Any{nil, "", int64(7), nil}, []bool{false, false, false, false}},
+			},
+			[]string{
+				"\t[0] before: @1=1",
+				"\t[0] after: @1=(unchanged) @2='x' @3=NULL @4=(unchanged)",
+				"\t[1] before: @1=2",
+				"\t[1] after: @1=(unchanged) @2='' @3=7 @4=(unchanged)",
+			},
+		},
+		{
+			[]Row{{[]Any{int64(3), nil, nil, nil}, []bool{false, false, false, false}}},
+			[]string{"\t[0] @1=3"},
+		},
+	}
+
+	events := readEvents(t, fde80+rowsMinimal)[2:]
+	for i, test := range tests {
+		event := events[i].(*RowsEvent)
+		if got := event.Rows(); !reflect.DeepEqual(got, test.rows) {
+			t.Errorf("%v: got rows %v, want %v", event.header.EventType, got, test.rows)
+		}
+
+		payload := event.GetPayload()
+		if got := payload[len(payload)-len(test.lines):]; !reflect.DeepEqual(got, test.lines) {
+			t.Errorf("%v: got %q, want %q", event.header.EventType, got, test.lines)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+//
+// rowsquery.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// ROWS_QUERY_LOG_EVENT, the original statement of the following rows events,
+// written with binlog_rows_query_log_events=ON, and ANNOTATE_ROWS_EVENT, its
+// counterpart of MariaDB with binlog_annotate_row_events=ON
+//
+
+package binlog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type RowsQueryEvent struct {
+	header *BinLogEventHeader
+	query  string
+	redact *RedactOptions
+}
+
+func (self *RowsQueryEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *RowsQueryEvent) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *RowsQueryEvent) GetPostHeader() []string {
+	return nil
+}
+
+func (self *RowsQueryEvent) GetPayload() []string {
+	return []string{fmt.Sprintf("query: %s", self.output())}
+}
+
+// Query returns the original statement of the following rows events
+func (self *RowsQueryEvent) Query() string {
+	return self.query
+}
+
+// output returns the query for the output, redacted if the parser is told to
+func (self *RowsQueryEvent) output() string {
+	if self.redact != nil {
+		return RedactQuery(self.query, self.redact)
+	}
+
+	return self.query
+}
+
+// comment returns the query as a comment to precede the statements
+// reconstructed from the rows events
+func (self *RowsQueryEvent) comment() string {
+	return "/* " + strings.Replace(self.output(), "*/", "* /", -1) + " */"
+}
+
+func newRowsQueryEvent(header *BinLogEventHeader, text []byte,
+	fde *FormatDescriptionEvent) (*RowsQueryEvent, error) {
+
+	end := len(text)
+	if fde.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
+		end -= BINLOG_CHECKSUM_LEN
+	}
+
+	// the length byte of ROWS_QUERY is the length truncated to 255, the query
+	// is the rest, while ANNOTATE_ROWS has only the query
+	start := 0
+	if header.EventType == ROWS_QUERY_LOG_EVENT {
+		start = 1
+	}
+
+	if end < start {
+		return nil, errors.New("Invalid RowsQueryEvent len")
+	}
+
+	return &RowsQueryEvent{header, string(text[start:end]), nil}, nil
+}
@@ -0,0 +1,415 @@
+//
+// sql.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// SQL statements reconstructed from row events
+//
+
+package binlog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// quoteName quotes an identifier with backticks
+func quoteName(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// columnRef returns the quoted name of the i-th column, or @N if the name is
+// unknown like mysqlbinlog, which is not valid SQL
+func (self *TableMapEvent) columnRef(i int) string {
+	if self.Metadata != nil && self.Metadata.ColumnNames != nil {
+		return quoteName(self.Metadata.ColumnNames[i])
+	}
+
+	return self.ColumnName(i)
+}
+
+func (self *TableMapEvent) tableRef() string {
+	return quoteName(self.Schema) + "." + quoteName(self.Table)
+}
+
+// value returns the i-th column of the row as a SQL expression. Unlike the
+// text output, a TIMESTAMP is converted from the seconds since the epoch and
+// a JSON value from the binary JSON, so that the statements write and match
+// the same values when replayed.
+func (self *RowsEvent) value(row []Any, i int) (string, error) {
+	val := self.columnValue(row, i)
+	t, meta := self.tableMap.ColumnTypes[i], self.tableMap.ColumnMeta[i]
+	switch v := val.(type) {
+	case time.Time:
+		return timestampSQL(v, int(meta)), nil
+	case []byte:
+		if t == MYSQL_TYPE_JSON {
+			text, err := decodeJSON(v)
+			if err != nil {
+				return "", fmt.Errorf("Column %s: %v", self.tableMap.ColumnName(i), err)
+			}
+
+			return jsonSQL(text), nil
+		}
+	case string:
+		// the redacted JSON value
+		if t == MYSQL_TYPE_JSON {
+			text, err := jsonText(v)
+			if err != nil {
+				return "", err
+			}
+
+			return jsonSQL(text), nil
+		}
+	}
+
+	return formatValue(val, t, meta), nil
+}
+
+// timestampSQL returns a TIMESTAMP value in the session time zone of the
+// replay, the zero value, which is out of the range of FROM_UNIXTIME, is
+// written as the zero date
+func timestampSQL(v time.Time, fsp int) string {
+	if v.Unix() == 0 && v.Nanosecond() == 0 {
+		return "'0000-00-00 00:00:00'"
+	}
+
+	usec := int64(v.Nanosecond() / 1000)
+	return "FROM_UNIXTIME(" + strconv.FormatInt(v.Unix(), 10) + formatFraction(usec, fsp) + ")"
+}
+
+func jsonSQL(text string) string {
+	return fmt.Sprintf("CAST(%s AS JSON)", quoteString([]byte(text)))
+}
+
+// SQLOptions controls the statements reconstructed from row events
+type SQLOptions struct {
+	// leave the invisible columns of MySQL 8.0.23+ out of INSERT and the SET
+	// of UPDATE, they are still used to identify the row
+	SkipInvisible bool
+}
+
+// includes reports whether the i-th column is set by INSERT or UPDATE
+func (self *RowsEvent) includes(i int, columns []byte, opts SQLOptions) bool {
+	return bitSet(columns, i) && (!opts.SkipInvisible || self.tableMap.IsVisible(i))
+}
+
+func (self *RowsEvent) insertColumns(opts SQLOptions) string {
+	var columns []string
+	for i := 0; i < self.columnCount; i++ {
+		if self.includes(i, self.columns, opts) {
+			columns = append(columns, self.tableMap.columnRef(i))
+		}
+	}
+
+	return strings.Join(columns, ", ")
+}
+
+func (self *RowsEvent) insertValues(row []Any, opts SQLOptions) (string, error) {
+	var values []string
+	for i := 0; i < self.columnCount; i++ {
+		if self.includes(i, self.columns, opts) {
+			value, err := self.value(row, i)
+			if err != nil {
+				return "", err
+			}
+
+			values = append(values, value)
+		}
+	}
+
+	return "(" + strings.Join(values, ", ") + ")", nil
+}
+
+func (self *RowsEvent) insertSQL(row []Any, opts SQLOptions) (string, error) {
+	values, err := self.insertValues(row, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", self.tableMap.tableRef(),
+		self.insertColumns(opts), values), nil
+}
+
+// whereClause identifies the row of the before image by the primary key if
+// all its columns are in the image, otherwise by all the columns in the image
+// and limited to one row. It fails if the image has no columns.
+func (self *RowsEvent) whereClause(row []Any) (string, error) {
+	var keys []int
+	if self.tableMap.Metadata != nil {
+		keys = self.tableMap.Metadata.PrimaryKey
+	}
+
+	for _, i := range keys {
+		if !bitSet(self.columns, i) {
+			keys = nil
+			break
+		}
+	}
+
+	limit := ""
+	if len(keys) == 0 {
+		limit = " LIMIT 1"
+		keys = nil
+		for i := 0; i < self.columnCount; i++ {
+			if bitSet(self.columns, i) {
+				keys = append(keys, i)
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		return "", fmt.Errorf("No column of %s in the before image to identify the row", self.tableMap.tableRef())
+	}
+
+	var conds []string
+	for _, i := range keys {
+		if row[i] == nil {
+			conds = append(conds, self.tableMap.columnRef(i)+" IS NULL")
+			continue
+		}
+
+		value, err := self.value(row, i)
+		if err != nil {
+			return "", err
+		}
+
+		conds = append(conds, self.tableMap.columnRef(i)+"="+value)
+	}
+
+	return " WHERE " + strings.Join(conds, " AND ") + limit, nil
+}
+
+// updateSQL returns "" if no column is set, e.g. only the invisible columns
+// are changed and they are skipped
+func (self *RowsEvent) updateSQL(before []Any, after []Any, opts SQLOptions) (string, error) {
+	var values []string
+	for i := 0; i < self.columnCount; i++ {
+		if self.includes(i, self.columnsAfter, opts) {
+			value, err := self.value(after, i)
+			if err != nil {
+				return "", err
+			}
+
+			values = append(values, self.tableMap.columnRef(i)+"="+value)
+		}
+	}
+
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	where, err := self.whereClause(before)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("UPDATE %s SET %s%s", self.tableMap.tableRef(), strings.Join(values, ", "), where), nil
+}
+
+func (self *RowsEvent) deleteSQL(row []Any) (string, error) {
+	where, err := self.whereClause(row)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("DELETE FROM %s%s", self.tableMap.tableRef(), where), nil
+}
+
+// SQL returns the statements which apply the row changes of the event, one
+// for each row. The column names and the primary key are known only from the
+// optional metadata of MySQL 8.0, without them the columns are referred as
+// @N like mysqlbinlog -v.
+func (self *RowsEvent) SQL() ([]string, error) {
+	return self.SQLWithOptions(SQLOptions{})
+}
+
+// SQLWithOptions is SQL with the options, an update which sets no column with
+// them is left out
+func (self *RowsEvent) SQLWithOptions(opts SQLOptions) ([]string, error) {
+	if self.err != nil {
+		return nil, self.err
+	}
+
+	if self.tableMap == nil {
+		return nil, errors.New("RowsEvent is not decoded")
+	}
+
+	var stmts []string
+	switch self.header.EventType {
+	case WRITE_ROWS_EVENT_V1, WRITE_ROWS_EVENT:
+		for _, row := range self.rows {
+			stmt, err := self.insertSQL(row, opts)
+			if err != nil {
+				return nil, err
+			}
+
+			stmts = append(stmts, stmt)
+		}
+	case DELETE_ROWS_EVENT_V1, DELETE_ROWS_EVENT:
+		for _, row := range self.rows {
+			stmt, err := self.deleteSQL(row)
+			if err != nil {
+				return nil, err
+			}
+
+			stmts = append(stmts, stmt)
+		}
+	default:
+		for i := 0; i+1 < len(self.rows); i += 2 {
+			stmt, err := self.updateSQL(self.rows[i], self.rows[i+1], opts)
+			if err != nil {
+				return nil, err
+			}
+
+			if stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+		}
+	}
+
+	return stmts, nil
+}
+
+// MergeWriteRowsSQL returns the statements of the rows events like SQL, but
+// the rows of adjacent WRITE_ROWS_EVENTs of the same table and columns are
+// inserted by one multi-row INSERT of at most maxRows rows, or unlimited if
+// maxRows <= 0. TABLE_MAP_EVENTs do not break the adjacency, while other
+// events do and are otherwise ignored.
+func MergeWriteRowsSQL(events []BinLogEvent, maxRows int) ([]string, error) {
+	var stmts []string
+	var last *RowsEvent
+	var values []string
+	flush := func() {
+		if len(values) != 0 {
+			stmts = append(stmts, fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", last.tableMap.tableRef(),
+				last.insertColumns(SQLOptions{}), strings.Join(values, ",")))
+			values = nil
+		}
+	}
+
+	for _, event := range events {
+		if event.Header().EventType == TABLE_MAP_EVENT {
+			continue
+		}
+
+		rows, ok := event.(*RowsEvent)
+		if !ok || rows.header.EventType != WRITE_ROWS_EVENT && rows.header.EventType != WRITE_ROWS_EVENT_V1 {
+			flush()
+			last = nil
+			if ok {
+				sqls, err := rows.SQL()
+				if err != nil {
+					return nil, err
+				}
+
+				stmts = append(stmts, sqls...)
+			}
+
+			continue
+		}
+
+		if rows.err != nil {
+			return nil, rows.err
+		}
+
+		if rows.tableMap == nil {
+			return nil, errors.New("RowsEvent is not decoded")
+		}
+
+		if last == nil || last.tableId != rows.tableId || !bytes.Equal(last.columns, rows.columns) {
+			flush()
+		}
+
+		last = rows
+		for _, row := range rows.rows {
+			if maxRows > 0 && len(values) >= maxRows {
+				flush()
+			}
+
+			value, err := rows.insertValues(row, SQLOptions{})
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, value)
+		}
+	}
+
+	flush()
+	return stmts, nil
+}
+
+// TransactionSQL returns the statements which reapply a transaction as
+// delivered by ForEachTransaction: the queries of statement based events, the
+// statements reconstructed from rows events, and COMMIT for XID_EVENT. A USE
+// statement precedes a query whose default database differs from the one of
+// the previous query, and BEGIN replaces the GTID_EVENT of a MariaDB
+// transaction. The rows events which are not decoded yet are decoded
+// with the table maps of the transaction, or else of tableMaps, which may be
+// nil. The INTVAR_EVENT, RAND_EVENT and USER_VAR_EVENT, which set the session
+// state of the following query in statement based replication, are turned
+// into SET statements like mysqlbinlog, so that e.g. the auto increment ids of
+// an INSERT are the same when replayed. The original statement of a
+// ROWS_QUERY_LOG_EVENT is put in a comment before the statements of the
+// following rows events.
+func TransactionSQL(txn []BinLogEvent, tableMaps map[uint64]*TableMapEvent) ([]string, error) {
+	maps := make(map[uint64]*TableMapEvent)
+	var stmts []string
+	schema, comment := "", ""
+	for _, event := range txn {
+		switch e := event.(type) {
+		case *QueryEvent:
+			if e.Schema() != "" && e.Schema() != schema {
+				schema = e.Schema()
+				stmts = append(stmts, e.UseStatement())
+			}
+
+			stmts = append(stmts, strings.TrimRight(strings.TrimSpace(e.query()), ";"))
+		case *MariadbGtidEvent:
+			if !e.IsStandalone() {
+				stmts = append(stmts, "BEGIN")
+			}
+		case *XidEvent:
+			stmts = append(stmts, "COMMIT")
+		case *IntvarEvent:
+			stmts = append(stmts, e.SQL())
+		case *RandEvent:
+			stmts = append(stmts, e.SQL())
+		case *UserVarEvent:
+			stmts = append(stmts, e.SQL())
+		case *RowsQueryEvent:
+			comment = e.comment()
+		case *TableMapEvent:
+			maps[e.TableId] = e
+		case *RowsEvent:
+			if e.tableMap == nil {
+				tableMap := maps[e.tableId]
+				if tableMap == nil {
+					tableMap = tableMaps[e.tableId]
+				}
+
+				decoded := *e
+				decoded.decodeRows(tableMap)
+				e = &decoded
+			}
+
+			sqls, err := e.SQL()
+			if err != nil {
+				return nil, err
+			}
+
+			if comment != "" && len(sqls) != 0 {
+				sqls[0] = comment + "\n" + sqls[0]
+				comment = ""
+			}
+
+			stmts = append(stmts, sqls...)
+		}
+	}
+
+	return stmts, nil
+}
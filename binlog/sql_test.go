@@ -0,0 +1,187 @@
+//
+// sql_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"reflect"
+	"testing"
+)
+
+// the table map of `test`.`js` (id INT, ts TIMESTAMP(3), doc JSON)
+const tableMapJS = `
+	# TABLE_MAP_EVENT at 123
+	80 4c 94 5d 13 01 00 00 00 31 00 00 00 ac 00 00 00 00 00 50 00 00 00 00
+	00 01 00 04 74 65 73 74 00 02 6a 73 00 03 03 11 f5 02 03 04 06 f8 f3 b8
+	40
+`
+
+// a transaction of `test`.`js` which starts after its table map, the rows are
+// (1, 1570000000.123, {"a": [1, "x<y"], "b": null}) and (2, 0, "")
+const rowsJS = `
+	# WRITE_ROWS_EVENT at 123
+	80 4c 94 5d 1e 01 00 00 00 64 00 00 00 df 00 00 00 00 00 50 00 00 00 00
+	00 01 00 02 00 03 07 00 01 00 00 00 5d 94 4c 80 04 ce 23 00 00 00 00 02
+	00 22 00 12 00 01 00 13 00 01 00 02 14 00 04 00 00 61 62 02 00 0e 00 05
+	01 00 0c 0a 00 03 78 3c 79 00 02 00 00 00 00 00 00 00 00 00 00 00 00 00
+	cd e4 aa 5a
+	# XID_EVENT at 223
+	80 4c 94 5d 10 01 00 00 00 1f 00 00 00 fe 00 00 00 00 00 09 00 00 00 00
+	00 00 00 01 c8 7c 21
+`
+
+func TestTransactionSQL(t *testing.T) {
+	tableMap := readEvents(t, fde57+tableMapJS)[1].(*TableMapEvent)
+	txn := readEvents(t, fde57+rowsJS)[1:]
+	if txn[0].(*RowsEvent).Err() == nil {
+		t.Fatal("the rows are decoded without the table map")
+	}
+
+	tests := []struct {
+		name      string
+		tableMaps map[uint64]*TableMapEvent
+		want      []string
+	}{
+		{"no table map", nil, nil},
+		{"other table", map[uint64]*TableMapEvent{81: tableMap}, nil},
+		{"table map", map[uint64]*TableMapEvent{80: tableMap}, []string{
+			"INSERT INTO `test`.`js` (@1, @2, @3) VALUES " +
+				`(1, FROM_UNIXTIME(1570000000.123), CAST('{"a":[1,"x<y"],"b":null}' AS JSON))`,
+			"INSERT INTO `test`.`js` (@1, @2, @3) VALUES (2, '0000-00-00 00:00:00', CAST('null' AS JSON))",
+			"COMMIT",
+		}},
+	}
+
+	for _, test := range tests {
+		stmts, err := TransactionSQL(txn, test.tableMaps)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("%s: got %q, want an error", test.name, stmts)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !reflect.DeepEqual(stmts, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, stmts, test.want)
+		}
+	}
+}
+
+// the queries in the default databases my-db, 数据库, a`b and none
+const querySchemas = `
+	# QUERY_EVENT at 123
+	80 4c 94 5d 02 01 00 00 00 54 00 00 00 cf 00 00 00 00 00 05 00 00 00 00
+	00 00 00 05 00 00 15 00 00 00 00 00 00 01 00 00 00 40 00 00 00 00 04 21
+	00 21 00 08 00 6d 79 2d 64 62 00 63 72 65 61 74 65 20 74 61 62 6c 65 20
+	74 28 61 20 69 6e 74 29 16 d5 32 b0
+	# QUERY_EVENT at 207
+	80 4c 94 5d 02 01 00 00 00 58 00 00 00 27 01 00 00 00 00 05 00 00 00 00
+	00 00 00 09 00 00 15 00 00 00 00 00 00 01 00 00 00 40 00 00 00 00 04 21
+	00 21 00 08 00 e6 95 b0 e6 8d ae e5 ba 93 00 63 72 65 61 74 65 20 74 61
+	62 6c 65 20 74 28 61 20 69 6e 74 29 2d 34 a8 93
+	# QUERY_EVENT at 295
+	80 4c 94 5d 02 01 00 00 00 52 00 00 00 79 01 00 00 00 00 05 00 00 00 00
+	00 00 00 03 00 00 15 00 00 00 00 00 00 01 00 00 00 40 00 00 00 00 04 21
+	00 21 00 08 00 61 60 62 00 63 72 65 61 74 65 20 74 61 62 6c 65 20 74 28
+	61 20 69 6e 74 29 e5 92 cb d3
+	# QUERY_EVENT at 377
+	80 4c 94 5d 02 01 00 00 00 4f 00 00 00 c8 01 00 00 00 00 05 00 00 00 00
+	00 00 00 00 00 00 15 00 00 00 00 00 00 01 00 00 00 40 00 00 00 00 04 21
+	00 21 00 08 00 00 63 72 65 61 74 65 20 74 61 62 6c 65 20 74 28 61 20 69
+	6e 74 29 36 3d f3 b5
+`
+
+func TestUseStatement(t *testing.T) {
+	tests := []struct {
+		schema string
+		use    string
+	}{
+		{"my-db", "USE `my-db`"},
+		{"数据库", "USE `数据库`"},
+		{"a`b", "USE `a``b`"},
+		{"", ""},
+	}
+
+	events := readEvents(t, fde57+querySchemas)[1:]
+	for i, test := range tests {
+		event := events[i].(*QueryEvent)
+		if event.Schema() != test.schema || event.UseStatement() != test.use {
+			t.Errorf("event %d: got schema %q and %q, want %q and %q", i, event.Schema(), event.UseStatement(),
+				test.schema, test.use)
+		}
+	}
+
+	stmts, err := TransactionSQL(events, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no USE for the query without a default database
+	want := []string{
+		"USE `my-db`", "create table t(a int)",
+		"USE `数据库`", "create table t(a int)",
+		"USE `a``b`", "create table t(a int)",
+		"create table t(a int)",
+	}
+	if !reflect.DeepEqual(stmts, want) {
+		t.Errorf("got %q, want %q", stmts, want)
+	}
+}
+
+// the table map of `test`.`v` (id INT, a INT, h INT INVISIBLE) with the
+// column names and visibility, the updates of only h in two rows, of a and h,
+// and of a with an empty before image
+const rowsInvisible = `
+	# TABLE_MAP_EVENT at 123
+	80 4c 94 5d 13 01 00 00 00 3a 00 00 00 b5 00 00 00 00 00 51 00 00 00 00
+	00 01 00 04 74 65 73 74 00 01 76 00 03 03 03 03 00 00 04 07 02 69 64 01
+	61 01 68 0c 01 c0 26 bb 63 a0
+	# UPDATE_ROWS_EVENT at 181
+	80 4c 94 5d 1f 01 00 00 00 48 00 00 00 fd 00 00 00 00 00 51 00 00 00 00
+	00 01 00 02 00 03 07 04 00 01 00 00 00 0a 00 00 00 64 00 00 00 00 c8 00
+	00 00 00 02 00 00 00 14 00 00 00 2c 01 00 00 00 90 01 00 00 0a dd ac 3e
+	# UPDATE_ROWS_EVENT at 253
+	80 4c 94 5d 1f 01 00 00 00 3a 00 00 00 37 01 00 00 00 00 51 00 00 00 00
+	00 01 00 02 00 03 07 06 00 03 00 00 00 1e 00 00 00 f4 01 00 00 00 1f 00
+	00 00 f5 01 00 00 1c 4a 05 80
+	# UPDATE_ROWS_EVENT at 311
+	80 4c 94 5d 1f 01 00 00 00 29 00 00 00 60 01 00 00 00 00 51 00 00 00 00
+	00 01 00 02 00 03 00 02 00 05 00 00 00 40 5d 21 7a
+`
+
+func TestUpdateSQLEmpty(t *testing.T) {
+	events := readEvents(t, fde80+rowsInvisible)
+	tests := []struct {
+		name string
+		opts SQLOptions
+		want []string // nil for an error
+	}{
+		{"only h", SQLOptions{}, []string{
+			"UPDATE `test`.`v` SET `h`=200 WHERE `id`=1 AND `a`=10 AND `h`=100 LIMIT 1",
+			"UPDATE `test`.`v` SET `h`=400 WHERE `id`=2 AND `a`=20 AND `h`=300 LIMIT 1",
+		}},
+		{"only h skipped", SQLOptions{SkipInvisible: true}, []string{}},
+		{"a and h", SQLOptions{}, []string{
+			"UPDATE `test`.`v` SET `a`=31, `h`=501 WHERE `id`=3 AND `a`=30 AND `h`=500 LIMIT 1",
+		}},
+		{"a and h skipped", SQLOptions{SkipInvisible: true}, []string{
+			"UPDATE `test`.`v` SET `a`=31 WHERE `id`=3 AND `a`=30 AND `h`=500 LIMIT 1",
+		}},
+		{"empty before image", SQLOptions{}, nil},
+	}
+
+	for i, test := range tests {
+		event := events[2+i/2].(*RowsEvent)
+		stmts, err := event.SQLWithOptions(test.opts)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("%s: got %q, want an error", test.name, stmts)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if len(stmts) != len(test.want) || len(stmts) > 0 && !reflect.DeepEqual(stmts, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, stmts, test.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+//
+// stream.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"context"
+	"io"
+)
+
+// Stream reads the rest of the binlog in a goroutine and publishes the events
+// on the returned channel until the end of the binlog or ctx is cancelled. A
+// fatal error is sent on the error channel, then both channels are closed.
+// The parser must not be used by others until the event channel is closed.
+//
+// The parser reuses its read buffer for every event, so an event retained
+// past the next read must not reference that buffer. The built-in decoders
+// copy everything they keep, an event type decoded elsewhere must do the same
+// to be consumed concurrently.
+func (self *Parser) Stream(ctx context.Context) (<-chan BinLogEvent, <-chan error) {
+	events := make(chan BinLogEvent)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			event, err := self.ReadEvent()
+			if err != nil {
+				if err != io.EOF {
+					errs <- err
+				}
+
+				return
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, errs
+}
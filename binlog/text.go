@@ -0,0 +1,45 @@
+//
+// text.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+)
+
+// RenderText returns the text with the invalid UTF-8 sequences, e.g. the
+// binary string literals of a query, replaced by <hex:...> of their bytes, so
+// that a mostly textual query stays readable while the binary bytes are not
+// lost or replaced silently by U+FFFD.
+func RenderText(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		start := i
+		for i < len(s) {
+			if r, size := utf8.DecodeRuneInString(s[i:]); r != utf8.RuneError || size != 1 {
+				break
+			}
+
+			i++
+		}
+
+		if i > start {
+			sb.WriteString("<hex:" + hex.EncodeToString([]byte(s[start:i])) + ">")
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(s[i:])
+		sb.WriteString(s[i : i+size])
+		i += size
+	}
+
+	return sb.String()
+}
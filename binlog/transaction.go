@@ -0,0 +1,181 @@
+//
+// transaction.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+var ErrTransactionTooLarge = errors.New("Transaction exceeds the max transaction size")
+
+// events which never belong to a transaction
+func isControlEvent(t LogEventType) bool {
+	switch t {
+	case START_EVENT_V3, FORMAT_DESCRIPTION_EVENT, ROTATE_EVENT, STOP_EVENT,
+		PREVIOUS_GTIDS_LOG_EVENT, HEARTBEAT_LOG_EVENT, INCIDENT_EVENT,
+		GTID_LIST_EVENT, BINLOG_CHECKPOINT_EVENT, START_ENCRYPTION_EVENT:
+		return true
+	default:
+		return false
+	}
+}
+
+func isQuery(event *QueryEvent, stmts ...string) bool {
+	query := bytes.TrimSpace(event.payload.Query)
+	for _, stmt := range stmts {
+		if len(query) >= len(stmt) && bytes.EqualFold(query[:len(stmt)], []byte(stmt)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type TxnState uint8
+
+const (
+	TXN_NONE     TxnState = 0 // not part of any transaction
+	TXN_BEGIN    TxnState = 1 // starts a new transaction
+	TXN_CONTINUE TxnState = 2 // part of the current transaction
+)
+
+// TransactionTracker follows the transaction boundaries in a sequence of
+// events, see ForEachTransaction for the rules.
+type TransactionTracker struct {
+	open  bool // a transaction is started and not terminated
+	inTxn bool // inside BEGIN/COMMIT
+}
+
+// Track returns how the next event relates to the transactions, and whether
+// it terminates the transaction it belongs to.
+func (self *TransactionTracker) Track(event BinLogEvent) (TxnState, bool) {
+	t := event.Header().EventType
+	if isControlEvent(t) {
+		return TXN_NONE, false
+	}
+
+	state := TXN_CONTINUE
+	if !self.open || t == GTID_LOG_EVENT || t == ANONYMOUS_GTID_LOG_EVENT || t == GTID_EVENT {
+		state = TXN_BEGIN
+		self.inTxn = false
+	}
+
+	// the GTID_EVENT of MariaDB replaces BEGIN
+	if gtid, ok := event.(*MariadbGtidEvent); ok && !gtid.IsStandalone() {
+		self.inTxn = true
+	}
+
+	self.open = true
+	end := false
+	switch t {
+	case XID_EVENT, XA_PREPARE_LOG_EVENT:
+		end = true
+	case QUERY_EVENT:
+		query := event.(*QueryEvent)
+		if isQuery(query, "BEGIN", "XA START") {
+			self.inTxn = true
+		} else if isQuery(query, "ROLLBACK TO") {
+			// rollback to savepoint does not terminate the transaction
+		} else if isQuery(query, "COMMIT", "ROLLBACK", "XA COMMIT", "XA ROLLBACK") {
+			end = true
+		} else if !self.inTxn {
+			end = true
+		}
+	}
+
+	if end {
+		self.open, self.inTxn = false, false
+	}
+
+	return state, end
+}
+
+// SetMaxTransactionSize limits the total event size in bytes that
+// ForEachTransaction buffers for one transaction, 0 means unlimited.
+func (self *Parser) SetMaxTransactionSize(size uint64) {
+	self.maxTxnSize = size
+}
+
+// ForEachTransaction reads the rest of the binlog and calls fn with the events
+// of each transaction, from GTID/BEGIN through XID/COMMIT. A statement outside
+// of BEGIN/COMMIT (DDL or a non-transactional autocommit statement) is
+// delivered as a transaction of its own, together with its GTID event if any.
+// Control events such as FORMAT_DESCRIPTION_EVENT or ROTATE_EVENT are not part
+// of any transaction and are not delivered. Events of an unterminated
+// transaction at the end of the binlog are delivered as the last group.
+//
+// The whole transaction is kept in memory until it is terminated, so a huge
+// transaction (e.g. a bulk DELETE in row format) needs memory proportional to
+// its size. Use SetMaxTransactionSize to fail with ErrTransactionTooLarge
+// instead of buffering without bound.
+func (self *Parser) ForEachTransaction(fn func(txn []BinLogEvent) error) error {
+	var tracker TransactionTracker
+	var txn []BinLogEvent
+	var size uint64
+	for {
+		event, err := self.ReadEvent()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return err
+		}
+
+		state, end := tracker.Track(event)
+		if state == TXN_NONE {
+			continue
+		}
+
+		if state == TXN_BEGIN && len(txn) != 0 {
+			if err = fn(txn); err != nil {
+				return err
+			}
+
+			txn, size = nil, 0
+		}
+
+		// the transaction_length of MySQL 8.0.2+ tells the size in advance
+		if gtid, ok := event.(*GtidLogEvent); ok && self.maxTxnSize != 0 && gtid.TransactionLength > self.maxTxnSize {
+			return ErrTransactionTooLarge
+		}
+
+		size += uint64(event.Header().EventSize)
+		if self.maxTxnSize != 0 && size > self.maxTxnSize {
+			return ErrTransactionTooLarge
+		}
+
+		txn = append(txn, event)
+		if end {
+			if err = fn(txn); err != nil {
+				return err
+			}
+
+			txn, size = nil, 0
+		}
+	}
+
+	if len(txn) != 0 {
+		return fn(txn)
+	}
+
+	return nil
+}
+
+// TransactionXid returns the XID of the XID_EVENT which commits a transaction
+// delivered by ForEachTransaction, ok is false if the transaction is not
+// committed by one, e.g. DDL or a non-transactional statement.
+func TransactionXid(txn []BinLogEvent) (xid uint64, ok bool) {
+	for i := len(txn) - 1; i >= 0; i-- {
+		if event, ok := txn[i].(*XidEvent); ok {
+			return event.Xid(), true
+		}
+	}
+
+	return 0, false
+}
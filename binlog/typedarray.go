@@ -0,0 +1,237 @@
+//
+// typedarray.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// TYPED_ARRAY column values of the multi-valued indexes of MySQL 8.0.17+,
+// which are stored as a binary JSON array, see sql/json_binary.cc
+//
+
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// binary JSON types
+const (
+	JSONB_TYPE_SMALL_OBJECT = 0x00
+	JSONB_TYPE_LARGE_OBJECT = 0x01
+	JSONB_TYPE_SMALL_ARRAY  = 0x02
+	JSONB_TYPE_LARGE_ARRAY  = 0x03
+	JSONB_TYPE_LITERAL      = 0x04
+	JSONB_TYPE_INT16        = 0x05
+	JSONB_TYPE_UINT16       = 0x06
+	JSONB_TYPE_INT32        = 0x07
+	JSONB_TYPE_UINT32       = 0x08
+	JSONB_TYPE_INT64        = 0x09
+	JSONB_TYPE_UINT64       = 0x0a
+	JSONB_TYPE_DOUBLE       = 0x0b
+	JSONB_TYPE_STRING       = 0x0c
+	JSONB_TYPE_OPAQUE       = 0x0f
+)
+
+// TypedArray is a decoded TYPED_ARRAY value, Values is nil and Raw is the
+// binary JSON if the array or its element type is not supported
+type TypedArray struct {
+	Values []Any  `json:"values"`
+	Raw    []byte `json:"raw,omitempty"`
+}
+
+// String returns the array as JSON text
+func (self *TypedArray) String() string {
+	if self.Values == nil {
+		return "0x" + hex.EncodeToString(self.Raw)
+	}
+
+	text, err := jsonText(self.Values)
+	if err != nil {
+		return fmt.Sprintf("%v", self.Values)
+	}
+
+	return text
+}
+
+// SQL returns the value as a SQL expression
+func (self *TypedArray) SQL() string {
+	if self.Values == nil {
+		return "0x" + hex.EncodeToString(self.Raw)
+	}
+
+	return fmt.Sprintf("CAST(%s AS JSON)", quoteString([]byte(self.String())))
+}
+
+func decodeTypedArray(data []byte) *TypedArray {
+	values, err := decodeJSONArray(data)
+	if err != nil {
+		return &TypedArray{Raw: data}
+	}
+
+	return &TypedArray{Values: values}
+}
+
+// decodeJSONArray decodes a binary JSON array, see decodeJSONContainer
+func decodeJSONArray(data []byte) ([]Any, error) {
+	if len(data) == 0 {
+		return nil, errors.New("Empty JSON value")
+	}
+
+	if data[0] != JSONB_TYPE_SMALL_ARRAY && data[0] != JSONB_TYPE_LARGE_ARRAY {
+		return nil, fmt.Errorf("Unsupported JSON type 0x%x", data[0])
+	}
+
+	val, err := decodeJSONValue(data[0], data[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return val.([]Any), nil
+}
+
+func jsonUint(data []byte, size int) uint64 {
+	if size == 2 {
+		return uint64(binary.LittleEndian.Uint16(data))
+	}
+
+	return uint64(binary.LittleEndian.Uint32(data))
+}
+
+// decodeJSONEntry decodes the value of an entry of the container doc, the
+// literals and the 16 bits integers, and also the 32 bits integers of a large
+// container, are inlined in the field of the entry
+func decodeJSONEntry(doc []byte, t byte, field []byte) (Any, error) {
+	switch {
+	case t == JSONB_TYPE_LITERAL, t == JSONB_TYPE_INT16, t == JSONB_TYPE_UINT16,
+		t == JSONB_TYPE_INT32 && len(field) == 4, t == JSONB_TYPE_UINT32 && len(field) == 4:
+		return decodeJSONValue(t, field)
+	}
+
+	offset := int(jsonUint(field, len(field)))
+	if offset >= len(doc) {
+		return nil, errors.New("Invalid JSON value offset")
+	}
+
+	return decodeJSONValue(t, doc[offset:])
+}
+
+// decodeJSONValue decodes a value of type t which starts at data
+func decodeJSONValue(t byte, data []byte) (Any, error) {
+	r := bytes.NewReader(data)
+	switch t {
+	case JSONB_TYPE_SMALL_OBJECT:
+		return decodeJSONContainer(data, 2, true)
+	case JSONB_TYPE_LARGE_OBJECT:
+		return decodeJSONContainer(data, 4, true)
+	case JSONB_TYPE_SMALL_ARRAY:
+		return decodeJSONContainer(data, 2, false)
+	case JSONB_TYPE_LARGE_ARRAY:
+		return decodeJSONContainer(data, 4, false)
+	case JSONB_TYPE_LITERAL:
+		val, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch val {
+		case 0:
+			return nil, nil
+		case 1:
+			return true, nil
+		case 2:
+			return false, nil
+		default:
+			return nil, fmt.Errorf("Invalid JSON literal %d", val)
+		}
+	case JSONB_TYPE_INT16:
+		val, err := readUintLE(r, 2)
+		return int64(int16(val)), err
+	case JSONB_TYPE_UINT16:
+		return readUintLE(r, 2)
+	case JSONB_TYPE_INT32:
+		val, err := readUintLE(r, 4)
+		return int64(int32(val)), err
+	case JSONB_TYPE_UINT32:
+		return readUintLE(r, 4)
+	case JSONB_TYPE_INT64:
+		val, err := readUintLE(r, 8)
+		return int64(val), err
+	case JSONB_TYPE_UINT64:
+		return readUintLE(r, 8)
+	case JSONB_TYPE_DOUBLE:
+		val, err := readUintLE(r, 8)
+		return math.Float64frombits(val), err
+	case JSONB_TYPE_STRING:
+		val, err := readJSONString(r)
+		return string(val), err
+	case JSONB_TYPE_OPAQUE:
+		return decodeJSONOpaque(r)
+	default:
+		return nil, fmt.Errorf("Unsupported JSON type 0x%x", t)
+	}
+}
+
+// readJSONString reads a string prefixed with its length, which is stored
+// in 7 bits per byte with the high bit set if more bytes follow
+func readJSONString(r *bytes.Reader) ([]byte, error) {
+	var length uint64
+	for i := 0; ; i++ {
+		if i == 5 {
+			return nil, errors.New("Invalid JSON string len")
+		}
+
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		length |= uint64(b&0x7f) << (7 * uint(i))
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	if length > uint64(r.Len()) {
+		return nil, errors.New("Invalid JSON string len")
+	}
+
+	val := make([]byte, length)
+	_, err := readFull(r, val)
+	return val, err
+}
+
+// decodeJSONOpaque decodes an opaque value, which is the column type followed
+// by the data, only DECIMAL and the temporal types are supported
+func decodeJSONOpaque(r *bytes.Reader) (Any, error) {
+	t, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readJSONString(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ColumnType(t) {
+	case MYSQL_TYPE_NEWDECIMAL:
+		if len(data) < 2 {
+			return nil, errors.New("Invalid JSON decimal")
+		}
+
+		val, err := decodeDecimal(bytes.NewReader(data[2:]), int(data[0]), int(data[1]))
+		return json.Number(val), err
+	case MYSQL_TYPE_DATE, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP, MYSQL_TYPE_TIME:
+		if len(data) < 8 {
+			return nil, fmt.Errorf("Invalid JSON %v", ColumnType(t))
+		}
+
+		return formatPackedTime(ColumnType(t), int64(binary.LittleEndian.Uint64(data))), nil
+	default:
+		return nil, fmt.Errorf("Unsupported JSON opaque type %v", ColumnType(t))
+	}
+}
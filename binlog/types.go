@@ -6,7 +6,8 @@
 package binlog
 
 import (
-	"github.com/google/uuid"
+	"fmt"
+	"strings"
 )
 
 type Any interface{}
@@ -83,6 +84,52 @@ const (
 	PARTIAL_UPDATE_ROWS_EVENT LogEventType = 39
 )
 
+// flags of the event header
+type LogEventFlags uint16
+
+const (
+	LOG_EVENT_BINLOG_IN_USE_F   LogEventFlags = 0x0001
+	LOG_EVENT_THREAD_SPECIFIC_F LogEventFlags = 0x0004
+	LOG_EVENT_SUPPRESS_USE_F    LogEventFlags = 0x0008
+	LOG_EVENT_ARTIFICIAL_F      LogEventFlags = 0x0020
+	LOG_EVENT_RELAY_LOG_F       LogEventFlags = 0x0040
+	LOG_EVENT_IGNORABLE_F       LogEventFlags = 0x0080
+	LOG_EVENT_NO_FILTER_F       LogEventFlags = 0x0100
+	LOG_EVENT_MTS_ISOLATE_F     LogEventFlags = 0x0200
+)
+
+var logEventFlagNames = []struct {
+	flag LogEventFlags
+	name string
+}{
+	{LOG_EVENT_BINLOG_IN_USE_F, "LOG_EVENT_BINLOG_IN_USE_F"},
+	{LOG_EVENT_THREAD_SPECIFIC_F, "LOG_EVENT_THREAD_SPECIFIC_F"},
+	{LOG_EVENT_SUPPRESS_USE_F, "LOG_EVENT_SUPPRESS_USE_F"},
+	{LOG_EVENT_ARTIFICIAL_F, "LOG_EVENT_ARTIFICIAL_F"},
+	{LOG_EVENT_RELAY_LOG_F, "LOG_EVENT_RELAY_LOG_F"},
+	{LOG_EVENT_IGNORABLE_F, "LOG_EVENT_IGNORABLE_F"},
+	{LOG_EVENT_NO_FILTER_F, "LOG_EVENT_NO_FILTER_F"},
+	{LOG_EVENT_MTS_ISOLATE_F, "LOG_EVENT_MTS_ISOLATE_F"},
+}
+
+// String returns the names of the flags joined by |, the unknown bits are
+// printed in hex
+func (self LogEventFlags) String() string {
+	var names []string
+	for _, f := range logEventFlagNames {
+		if self&f.flag != 0 {
+			names = append(names, f.name)
+			self &^= f.flag
+		}
+	}
+
+	if self != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint16(self)))
+	}
+
+	return strings.Join(names, "|")
+}
+
 type BinlogChecksumAlg uint8
 
 const (
@@ -91,11 +138,23 @@ const (
 	BINLOG_CHECKSUM_ALG_END   BinlogChecksumAlg = 2
 )
 
+func (self BinlogChecksumAlg) String() string {
+	switch self {
+	case BINLOG_CHECKSUM_ALG_OFF:
+		return "OFF"
+	case BINLOG_CHECKSUM_ALG_CRC32:
+		return "CRC32"
+	default:
+		return fmt.Sprintf("UNDEFINED(%d)", uint8(self))
+	}
+}
+
 const (
 	BINLOG_EVENT_HEADER_LEN     = 19
 	QUERY_EVENT_POST_HEADER_LEN = 13
 	BINLOG_CHECKSUM_LEN         = 4
 	BINLOG_CHECKSUM_ALG_LEN     = 1
+	MAX_EVENT_SIZE              = 1 << 30 // max_allowed_packet is at most 1G
 )
 
 func (self LogEventType) String() string {
@@ -151,7 +210,7 @@ func (self LogEventType) String() string {
 	case UPDATE_ROWS_EVENT:
 		return "UPDATE_ROWS_EVENT"
 	case DELETE_ROWS_EVENT:
-		return "DELETE_FILE_EVENT"
+		return "DELETE_ROWS_EVENT"
 	case GTID_LOG_EVENT:
 		return "GTID_LOG_EVENT"
 	case ANONYMOUS_GTID_LOG_EVENT:
@@ -166,14 +225,30 @@ func (self LogEventType) String() string {
 		return "XA_PREPARE_LOG_EVENT"
 	case PARTIAL_UPDATE_ROWS_EVENT:
 		return "PARTIAL_UPDATE_ROWS_EVENT"
+	case ANNOTATE_ROWS_EVENT:
+		return "ANNOTATE_ROWS_EVENT"
+	case BINLOG_CHECKPOINT_EVENT:
+		return "BINLOG_CHECKPOINT_EVENT"
+	case GTID_EVENT:
+		return "GTID_EVENT"
+	case GTID_LIST_EVENT:
+		return "GTID_LIST_EVENT"
+	case START_ENCRYPTION_EVENT:
+		return "START_ENCRYPTION_EVENT"
 	default:
 		return "INVALID"
 	}
 }
 
-type GTIDSet struct {
-	Gtid     uuid.UUID
-	Interval uint64
-	From     uint64
-	To       uint64
+// ParseLogEventType is the reverse of LogEventType.String(), it maps names like
+// QUERY_EVENT back to their event type, the name is case insensitive.
+func ParseLogEventType(name string) (LogEventType, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	for i := 0; i <= 255; i++ {
+		if t := LogEventType(i); t.String() != "INVALID" && t.String() == name {
+			return t, nil
+		}
+	}
+
+	return UNKNOWN_EVENT, fmt.Errorf("Unknown event type: %s", name)
 }
@@ -0,0 +1,173 @@
+//
+// uservar.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// USER_VAR_EVENT, the value of a user variable used by the following
+// statement in statement based replication
+//
+
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Item_result of the user variable value
+const (
+	STRING_RESULT  = 0
+	REAL_RESULT    = 1
+	INT_RESULT     = 2
+	ROW_RESULT     = 3
+	DECIMAL_RESULT = 4
+)
+
+// flags of USER_VAR_EVENT, written after the value by MySQL 5.6+
+const (
+	USER_VAR_UNSIGNED_F = 0x1
+)
+
+type UserVarEvent struct {
+	header  *BinLogEventHeader
+	Name    string
+	IsNull  bool
+	Type    uint8 // Item_result
+	Charset uint32
+	Value   []byte // raw value
+	Flags   uint8
+	redact  *RedactOptions
+}
+
+func (self *UserVarEvent) Header() *BinLogEventHeader {
+	return self.header
+}
+
+func (self *UserVarEvent) GetHeader() []string {
+	return self.header.Desc()
+}
+
+func (self *UserVarEvent) GetPostHeader() []string {
+	return nil
+}
+
+func (self *UserVarEvent) GetPayload() []string {
+	return []string{
+		fmt.Sprintf("name: %s", self.Name),
+		fmt.Sprintf("type: %d", self.Type),
+		fmt.Sprintf("charset: %d", self.Charset),
+		fmt.Sprintf("flags: 0x%x", self.Flags),
+		fmt.Sprintf("value: %s", self.FormatValue()),
+	}
+}
+
+// IsUnsigned reports whether an INT_RESULT value is unsigned
+func (self *UserVarEvent) IsUnsigned() bool {
+	return self.Flags&USER_VAR_UNSIGNED_F != 0
+}
+
+// FormatValue formats the value as a SQL literal like mysqlbinlog, a string
+// value is redacted if the parser is told to
+func (self *UserVarEvent) FormatValue() string {
+	if self.IsNull {
+		return "NULL"
+	}
+
+	switch self.Type {
+	case REAL_RESULT:
+		if len(self.Value) < 8 {
+			break
+		}
+
+		val := math.Float64frombits(binary.LittleEndian.Uint64(self.Value))
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case INT_RESULT:
+		if len(self.Value) < 8 {
+			break
+		}
+
+		val := binary.LittleEndian.Uint64(self.Value)
+		if self.IsUnsigned() {
+			return strconv.FormatUint(val, 10)
+		}
+
+		return strconv.FormatInt(int64(val), 10)
+	case DECIMAL_RESULT:
+		// precision and scale followed by the binary DECIMAL
+		if len(self.Value) < 2 {
+			break
+		}
+
+		val, err := decodeDecimal(bytes.NewReader(self.Value[2:]), int(self.Value[0]), int(self.Value[1]))
+		if err == nil {
+			return val
+		}
+	case STRING_RESULT:
+		if self.redact != nil {
+			return quoteString([]byte(self.redact.redact(self.Value)))
+		}
+
+		return quoteString(self.Value)
+	}
+
+	return fmt.Sprintf("%x", self.Value)
+}
+
+// SQL returns the statement which sets the user variable like mysqlbinlog,
+// without the charset introducer of a string value
+func (self *UserVarEvent) SQL() string {
+	return fmt.Sprintf("SET @%s:=%s", quoteName(self.Name), self.FormatValue())
+}
+
+func newUserVarEvent(header *BinLogEventHeader, text []byte,
+	fde *FormatDescriptionEvent) (*UserVarEvent, error) {
+
+	end := len(text)
+	if fde.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
+		end -= BINLOG_CHECKSUM_LEN
+	}
+
+	if end < 4 {
+		return nil, errors.New("Invalid UserVarEvent len")
+	}
+
+	event := new(UserVarEvent)
+	event.header = header
+	size := int(binary.LittleEndian.Uint32(text))
+	if size > end-4-1 {
+		return nil, errors.New("Invalid UserVarEvent name len")
+	}
+
+	event.Name = string(text[4 : 4+size])
+	pos := 4 + size
+	event.IsNull = text[pos] != 0
+	pos++
+	if event.IsNull {
+		return event, nil
+	}
+
+	if end-pos < 1+4+4 {
+		return nil, errors.New("Invalid UserVarEvent len")
+	}
+
+	event.Type = text[pos]
+	event.Charset = binary.LittleEndian.Uint32(text[pos+1:])
+	size = int(binary.LittleEndian.Uint32(text[pos+5:]))
+	pos += 9
+	if size > end-pos {
+		return nil, errors.New("Invalid UserVarEvent value len")
+	}
+
+	event.Value = append([]byte(nil), text[pos:pos+size]...)
+	pos += size
+
+	// the flags are optional, absent in the binlogs of MySQL 5.5 and older
+	if pos < end {
+		event.Flags = text[pos]
+	}
+
+	return event, nil
+}
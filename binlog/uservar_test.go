@@ -0,0 +1,107 @@
+//
+// uservar_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"strings"
+	"testing"
+)
+
+// the user variables near the uint64 max with the unsigned flag, with the
+// flags and without the flags like MySQL 5.5, followed by the NULL, string
+// and real variables
+const userVars = `
+	# USER_VAR_EVENT at 123
+	80 4c 94 5d 0e 01 00 00 00 2f 00 00 00 aa 00 00 00 00 00 01 00 00 00 75
+	00 02 3f 00 00 00 08 00 00 00 fe ff ff ff ff ff ff ff 01 7a d6 bf d0
+	# USER_VAR_EVENT at 170
+	80 4c 94 5d 0e 01 00 00 00 2f 00 00 00 d9 00 00 00 00 00 01 00 00 00 75
+	00 02 3f 00 00 00 08 00 00 00 fe ff ff ff ff ff ff ff 00 bb 71 ec 6a
+	# USER_VAR_EVENT at 217
+	80 4c 94 5d 0e 01 00 00 00 2e 00 00 00 07 01 00 00 00 00 01 00 00 00 75
+	00 02 3f 00 00 00 08 00 00 00 fe ff ff ff ff ff ff ff 9e 8f da 46
+	# USER_VAR_EVENT at 263
+	80 4c 94 5d 0e 01 00 00 00 1d 00 00 00 24 01 00 00 00 00 01 00 00 00 6e
+	01 fd 1b a2 86
+	# USER_VAR_EVENT at 292
+	80 4c 94 5d 0e 01 00 00 00 2b 00 00 00 4f 01 00 00 00 00 01 00 00 00 73
+	00 00 21 00 00 00 04 00 00 00 69 74 27 73 00 0d bc f0 8f
+	# USER_VAR_EVENT at 335
+	80 4c 94 5d 0e 01 00 00 00 2f 00 00 00 7e 01 00 00 00 00 01 00 00 00 72
+	00 01 3f 00 00 00 08 00 00 00 00 00 00 00 00 00 f8 3f 00 64 11 09 b3
+`
+
+func TestUserVar(t *testing.T) {
+	tests := []struct {
+		unsigned bool
+		sql      string
+	}{
+		{true, "SET @`u`:=18446744073709551614"},
+		{false, "SET @`u`:=-2"},
+		{false, "SET @`u`:=-2"},
+		{false, "SET @`n`:=NULL"},
+		{false, "SET @`s`:='it\\'s'"},
+		{false, "SET @`r`:=1.5"},
+	}
+
+	events := readEvents(t, fde57+userVars)[1:]
+	for i, test := range tests {
+		event := events[i].(*UserVarEvent)
+		if event.IsUnsigned() != test.unsigned || event.SQL() != test.sql {
+			t.Errorf("event %d: got %s, unsigned %v, want %s, unsigned %v", i, event.SQL(), event.IsUnsigned(),
+				test.sql, test.unsigned)
+		}
+	}
+}
+
+func TestUserVarRedacted(t *testing.T) {
+	tests := []struct {
+		opts  *RedactOptions
+		value string
+	}{
+		{nil, "'secret'"},
+		{&RedactOptions{}, "'<redacted len=6>'"},
+		{&RedactOptions{Hash: true}, "'<sha256=2bb80d537b1da3e3 len=6>'"},
+	}
+
+	for _, test := range tests {
+		events := readRedactedEvents(t, fde57+rowsSecret, test.opts)
+		event := events[1].(*UserVarEvent)
+		if text := event.FormatValue(); text != test.value {
+			t.Errorf("got %s, want %s", text, test.value)
+		}
+
+		if stmt := event.SQL(); stmt != "SET @`v`:="+test.value {
+			t.Errorf("got %s", stmt)
+		}
+
+		if line := event.GetPayload()[4]; line != "value: "+test.value {
+			t.Errorf("got %s", line)
+		}
+
+		stmts, err := TransactionSQL(events[1:], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stmts[0] != "SET @`v`:="+test.value {
+			t.Errorf("got %q", stmts)
+		}
+
+		if test.opts == nil {
+			continue
+		}
+
+		line, err := MarshalEventNDJSON(event, 123)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Contains(string(line), "secret") {
+			t.Errorf("the ndjson is not redacted: %s", line)
+		}
+	}
+}
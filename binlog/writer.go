@@ -0,0 +1,78 @@
+//
+// writer.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Write the raw events to a new binlog
+//
+
+package binlog
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// Writer writes the raw events read by a Parser, see RawEvent, to a new
+// binlog. The log_pos of each event is set to its end in the new binlog and
+// the checksum is recomputed, so the events can be taken from anywhere in
+// the source binlogs. The first event must be a FormatDescriptionEvent, which
+// decides the checksum of the following events.
+type Writer struct {
+	w        io.Writer
+	pos      int64
+	fde      bool
+	checksum bool
+}
+
+// NewWriter writes the magic number of a binlog to w and returns a Writer of
+// the events after it
+func NewWriter(w io.Writer) (*Writer, error) {
+	if _, err := w.Write(binlogMagic); err != nil {
+		return nil, err
+	}
+
+	return &Writer{w: w, pos: int64(len(binlogMagic))}, nil
+}
+
+// WriteEvent writes a raw event, from the common header to the checksum. The
+// LOG_EVENT_BINLOG_IN_USE_F of a FormatDescriptionEvent is cleared, since the
+// new binlog is not being written by a server.
+func (self *Writer) WriteEvent(raw []byte) error {
+	if len(raw) < BINLOG_EVENT_HEADER_LEN || binary.LittleEndian.Uint32(raw[9:]) != uint32(len(raw)) {
+		return errors.New("Invalid raw event len")
+	}
+
+	event := append([]byte(nil), raw...)
+	if LogEventType(event[4]) == FORMAT_DESCRIPTION_EVENT {
+		fde, err := DecodeEvent(event[:BINLOG_EVENT_HEADER_LEN], event[BINLOG_EVENT_HEADER_LEN:], nil)
+		if err != nil {
+			return err
+		}
+
+		self.fde = true
+		self.checksum = fde.(*FormatDescriptionEvent).ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32
+		flags := binary.LittleEndian.Uint16(event[17:]) &^ uint16(LOG_EVENT_BINLOG_IN_USE_F)
+		binary.LittleEndian.PutUint16(event[17:], flags)
+	} else if !self.fde {
+		return errors.New("The first event written is not a FormatDescriptionEvent")
+	}
+
+	if self.checksum && len(event) < BINLOG_EVENT_HEADER_LEN+BINLOG_CHECKSUM_LEN {
+		return errors.New("Invalid raw event len")
+	}
+
+	binary.LittleEndian.PutUint32(event[13:], uint32(self.pos+int64(len(event))))
+	if self.checksum {
+		n := len(event) - BINLOG_CHECKSUM_LEN
+		binary.LittleEndian.PutUint32(event[n:], crc32.ChecksumIEEE(event[:n]))
+	}
+
+	if _, err := self.w.Write(event); err != nil {
+		return err
+	}
+
+	self.pos += int64(len(event))
+	return nil
+}
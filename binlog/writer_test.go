@@ -0,0 +1,137 @@
+//
+// writer_test.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package binlog
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type rawEvent struct {
+	raw   []byte
+	event BinLogEvent
+}
+
+// readRawEvents reads the events of a binlog with their raw bytes, the
+// checksum of each event must be valid and its log_pos must be its end
+func readRawEvents(t *testing.T, data []byte) []rawEvent {
+	t.Helper()
+	parser, err := NewParser(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []rawEvent
+	for {
+		event, err := parser.ReadEvent()
+		if err == io.EOF {
+			return events
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !parser.hasChecksum() {
+			t.Errorf("%v at %d: invalid checksum", event.Header().EventType, parser.Position())
+		}
+
+		events = append(events, rawEvent{parser.RawEvent(), event})
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	source := readRawEvents(t, unhex(t, fde80+gtid80+query80))
+	tests := []struct {
+		name string
+		keep []int // the indexes of the source events which are written
+	}{
+		{"all", []int{0, 1, 2}},
+		{"without the GTID", []int{0, 2}},
+		{"FDE only", []int{0}},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		writer, err := NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, i := range test.keep {
+			if err = writer.WriteEvent(source[i].raw); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+		}
+
+		events := readRawEvents(t, buf.Bytes())
+		if len(events) != len(test.keep) {
+			t.Fatalf("%s: got %d events, want %d", test.name, len(events), len(test.keep))
+		}
+
+		pos := int64(len(binlogMagic))
+		for j, i := range test.keep {
+			got, want := events[j].event, source[i].event
+			pos += int64(len(events[j].raw))
+			if got.Header().LogPos != uint32(pos) {
+				t.Errorf("%s: %v log_pos %d, want %d", test.name, want.Header().EventType, got.Header().LogPos, pos)
+			}
+
+			// the events are the same except for the log_pos and the
+			// in use flag of the FDE
+			header := *want.Header()
+			header.LogPos = uint32(pos)
+			header.Flags &^= LOG_EVENT_BINLOG_IN_USE_F
+			if !reflect.DeepEqual(*got.Header(), header) {
+				t.Errorf("%s: got header %+v, want %+v", test.name, *got.Header(), header)
+			}
+
+			gotJSON, err := MarshalEventNDJSON(got, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantJSON, err := MarshalEventNDJSON(want, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(gotJSON, wantJSON) {
+				t.Errorf("%s: got %s, want %s", test.name, gotJSON, wantJSON)
+			}
+		}
+	}
+}
+
+func TestWriterInvalid(t *testing.T) {
+	source := readRawEvents(t, unhex(t, fde80+gtid80))
+	truncated := append([]byte(nil), source[0].raw[:40]...)
+	tests := []struct {
+		name string
+		raw  [][]byte
+	}{
+		{"no FDE first", [][]byte{source[1].raw}},
+		{"truncated", [][]byte{truncated}},
+		{"short", [][]byte{source[0].raw[:10]}},
+	}
+
+	for _, test := range tests {
+		writer, err := NewWriter(io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, raw := range test.raw {
+			err = writer.WriteEvent(raw)
+		}
+
+		if err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
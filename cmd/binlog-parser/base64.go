@@ -0,0 +1,186 @@
+//
+// base64.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Print the events as a SQL script like mysqlbinlog --base64-output
+//
+
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"io"
+	"strings"
+)
+
+// the --base64-output modes of mysqlbinlog
+const (
+	BASE64_AUTO        = "AUTO"        // BINLOG statements for the rows events
+	BASE64_ALWAYS      = "ALWAYS"      // BINLOG statements for all the events
+	BASE64_NEVER       = "NEVER"       // fail on the rows events
+	BASE64_DECODE_ROWS = "DECODE-ROWS" // the rows events as SQL in comments
+)
+
+func parseBase64Mode(mode string) (string, error) {
+	switch mode = strings.ToUpper(mode); mode {
+	case BASE64_AUTO, BASE64_ALWAYS, BASE64_NEVER, BASE64_DECODE_ROWS:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown base64 output mode: %s", mode)
+	}
+}
+
+// sqlScript prints the events as a SQL script which can be piped to the mysql
+// client, the BINLOG statements are base64 of the raw events and are applied
+// with the format description event printed before them
+type sqlScript struct {
+	w       io.Writer
+	mode    string
+	redact  *RedactOptions
+	schema  string
+	fde     bool     // the BINLOG statement of the FDE is printed
+	pending []string // base64 of the table maps and rows events of the statement
+}
+
+func newSQLScript(w io.Writer, mode string, redact *RedactOptions) *sqlScript {
+	fmt.Fprintln(w, "DELIMITER /*!*/;")
+	return &sqlScript{w: w, mode: mode, redact: redact}
+}
+
+func (self *sqlScript) close() {
+	fmt.Fprintln(self.w, "DELIMITER ;")
+}
+
+func (self *sqlScript) binlog(lines []string) {
+	fmt.Fprintf(self.w, "BINLOG '\n%s\n'/*!*/;\n", strings.Join(lines, "\n"))
+}
+
+// encode returns the base64 of the raw event in lines of 76 characters
+func encode(raw []byte) []string {
+	text := base64.StdEncoding.EncodeToString(raw)
+	var lines []string
+	for len(text) > 76 {
+		lines = append(lines, text[:76])
+		text = text[76:]
+	}
+
+	return append(lines, text)
+}
+
+func (self *sqlScript) statement(format string, args ...interface{}) {
+	fmt.Fprintf(self.w, format+"/*!*/;\n", args...)
+}
+
+// print prints the event at pos, raw is its bytes
+func (self *sqlScript) print(event BinLogEvent, pos int64, raw []byte) error {
+	fmt.Fprintf(self.w, "# at %d\n", pos)
+	header := event.Header()
+	if header.EventType == FORMAT_DESCRIPTION_EVENT {
+		self.fde = false
+		if self.mode == BASE64_AUTO || self.mode == BASE64_ALWAYS {
+			self.binlog(encode(raw))
+			self.fde = true
+		}
+
+		return nil
+	}
+
+	if self.mode == BASE64_ALWAYS {
+		if !self.fde {
+			return errors.New("The BINLOG statements need the format description event, which is not printed")
+		}
+
+		self.binlog(encode(raw))
+		return nil
+	}
+
+	switch e := event.(type) {
+	case *TableMapEvent:
+		return self.printRows(e, raw, false)
+	case *RowsEvent:
+		return self.printRows(e, raw, e.Flags()&STMT_END_F != 0)
+	case *QueryEvent:
+		if e.Schema() != "" && e.Schema() != self.schema {
+			self.schema = e.Schema()
+			self.statement("%s", e.UseStatement())
+		}
+
+		query := e.Query()
+		if self.redact != nil {
+			query = RedactQuery(query, self.redact)
+		}
+
+		self.statement("SET TIMESTAMP=%d", header.Timestamp)
+		fmt.Fprintf(self.w, "%s\n/*!*/;\n", query)
+	case *RowsQueryEvent:
+		query := e.Query()
+		if self.redact != nil {
+			query = RedactQuery(query, self.redact)
+		}
+
+		fmt.Fprintf(self.w, "# %s\n", strings.Replace(query, "\n", "\n# ", -1))
+	case *GtidLogEvent:
+		self.statement("SET @@SESSION.GTID_NEXT= '%s'", e.String())
+	case *MariadbGtidEvent:
+		self.statement("SET @@session.gtid_domain_id=%d", e.Gtid.DomainId)
+		self.statement("SET @@session.server_id=%d", e.Gtid.ServerId)
+		self.statement("SET @@session.gtid_seq_no=%d", e.Gtid.SeqNo)
+		if !e.IsStandalone() {
+			self.statement("BEGIN")
+		}
+	case *XidEvent:
+		self.statement("COMMIT")
+	case *IntvarEvent:
+		self.statement("%s", e.SQL())
+	case *RandEvent:
+		self.statement("%s", e.SQL())
+	case *UserVarEvent:
+		self.statement("%s", e.SQL())
+	default:
+		fmt.Fprintf(self.w, "# %v\n", header.EventType)
+	}
+
+	return nil
+}
+
+// printRows prints a table map or a rows event, in AUTO mode the events of a
+// statement are printed as one BINLOG statement at its end
+func (self *sqlScript) printRows(event BinLogEvent, raw []byte, end bool) error {
+	switch self.mode {
+	case BASE64_NEVER:
+		return fmt.Errorf("The %v can not be printed with --base64-output NEVER", event.Header().EventType)
+	case BASE64_DECODE_ROWS:
+		rows, ok := event.(*RowsEvent)
+		if !ok {
+			table := event.(*TableMapEvent)
+			fmt.Fprintf(self.w, "# Table_map: `%s`.`%s` mapped to number %d\n", table.Schema, table.Table, table.TableId)
+			return nil
+		}
+
+		stmts, err := rows.SQL()
+		if err != nil {
+			return err
+		}
+
+		for _, stmt := range stmts {
+			fmt.Fprintf(self.w, "### %s\n", strings.Replace(stmt, "\n", "\n### ", -1))
+		}
+
+		return nil
+	}
+
+	if !self.fde {
+		return errors.New("The BINLOG statements need the format description event, which is not printed")
+	}
+
+	self.pending = append(self.pending, encode(raw)...)
+	if end {
+		self.binlog(self.pending)
+		self.pending = nil
+	}
+
+	return nil
+}
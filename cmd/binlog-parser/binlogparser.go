@@ -8,30 +8,292 @@
 package main
 
 import (
+	"bufio"
+	"fmt"
 	"github.com/alexflint/go-arg"
 	. "github.com/chenjianlong/mysql-toolset/binlog"
 	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
 )
 
+// the buffered stdout or --output file
+var output *bufio.Writer
+
 func main() {
 	var args struct {
-		Path  string `arg:"-p,required" help:"binlog path"`
-		Start int    `arg:"-s" default:"0" help:"start event"`
-		Count int    `arg:"-c" default:"-1" help:"show event count"`
+		Path             string   `arg:"-p,required" help:"binlog path, or a directory of binlogs with --summary"`
+		Start            int      `arg:"-s" default:"0" help:"start event"`
+		Count            int      `arg:"-c" default:"-1" help:"show event count"`
+		Types            []string `arg:"-t,--event-type" help:"only show events of these types, e.g. QUERY_EVENT"`
+		Top              int      `arg:"--top-transactions" help:"report the N largest transactions"`
+		Relay            bool     `arg:"--relay-log" help:"the file is a relay log"`
+		Progress         bool     `arg:"--progress" help:"show progress on stderr"`
+		StartPos         int64    `arg:"--start-position" help:"start reading at the event at this offset"`
+		StopPos          int64    `arg:"--stop-position" help:"stop reading at the event ending after this offset"`
+		Validate         bool     `arg:"--validate" help:"parse every event and report the first error"`
+		DDLOnly          bool     `arg:"--ddl-only" help:"only print the DDL statements as SQL"`
+		CheckTimestamps  bool     `arg:"--check-timestamps" help:"warn on stderr when the event timestamp goes backwards"`
+		ChangeRecords    bool     `arg:"--change-records" help:"print the row changes as Debezium style JSON records"`
+		At               int64    `arg:"--at" help:"print the single event at this offset"`
+		Follow           bool     `arg:"-f,--follow" help:"wait for new events at the end of the binlog and follow the rotation like tail -f"`
+		Format           string   `arg:"--format" default:"text" help:"output format of the events: text, json, ndjson (one object per line with a constant envelope) or proto (length-delimited)"`
+		Summary          bool     `arg:"--summary" help:"print the counts of the transactions, DML, DDL and administrative events"`
+		Databases        []string `arg:"--database" help:"only show events of these databases, e.g. db1,db2"`
+		ExcludeDatabases []string `arg:"--exclude-database" help:"do not show events of these databases"`
+		Tail             int      `arg:"--tail" help:"only show the last N events, the binlog must be seekable"`
+		WarnEventSize    uint32   `arg:"--warn-event-size" help:"warn about the events larger than this many bytes in the summary and validate modes, e.g. 67108864 for a 64M max_allowed_packet"`
+		Redact           bool     `arg:"--redact" help:"redact the string values and the string literals of the queries"`
+		RedactHash       bool     `arg:"--redact-hash" help:"redact the string values with a hash, so that equal values can be told"`
+		Unsupported      bool     `arg:"--list-unsupported" help:"list the types of the events which are not decoded with their counts"`
+		Output           string   `arg:"-o,--output" help:"write to this file instead of stdout"`
+		Out              string   `arg:"--out" help:"same as --output"`
+		SkipGtids        bool     `arg:"--skip-gtids" help:"do not show the GTID events, they are still used for the change records"`
+		IncludeGtids     string   `arg:"--include-gtids" help:"only show the transactions whose GTID is in this set, e.g. uuid:1-100:200-250"`
+		ExcludeGtids     string   `arg:"--exclude-gtids" help:"do not show the transactions whose GTID is in this set"`
+		NDJSON           bool     `arg:"--ndjson" help:"same as --format ndjson"`
+		SlowQueries      int      `arg:"--slow-queries" default:"-1" help:"report the queries whose execution time on the master exceeds N seconds, the slowest first"`
+		StrictFDE        bool     `arg:"--strict-fde" help:"fail instead of reading the format description event first when starting after it, e.g. with --start-position"`
+		FormatDesc       bool     `arg:"--format-description" help:"print the binlog version, server version, checksum algorithm and create timestamp of the binlog as a JSON line"`
+		Base64Output     string   `arg:"--base64-output" help:"print the events as a SQL script like mysqlbinlog, the rows events as BINLOG statements (AUTO), all the events as BINLOG statements (ALWAYS), fail on the rows events (NEVER) or the rows events as SQL comments (DECODE-ROWS)"`
+		GtidSet          bool     `arg:"--gtid-set" help:"print the GTIDs of the previous binlogs and of the transactions of this binlog"`
+		SkipCorrupt      bool     `arg:"--skip-corrupt" help:"skip the events which can not be decoded and resync at the next event, the skipped bytes are reported on stderr"`
+		Interactive      bool     `arg:"-i,--interactive" help:"print a transaction at a time and wait for Enter, q quits, ignored if the output is not a terminal"`
+		UTC              bool     `arg:"--utc" help:"show the timestamps in UTC instead of local time"`
+		ExtractFrom      string   `arg:"--extract-from-gtid" help:"write a binlog of the format description event and the transactions from this GTID on, e.g. uuid:23, to --out"`
+		Threads          int      `arg:"--threads" help:"parse up to N binlogs of a directory at the same time with --summary, the number of CPUs by default"`
+		Verbose          int      `arg:"--verbose" help:"detail of the events in the text format: the header only (0), the post header and the payload too (1, -v) or the SQL of the queries and the rows with the column names too (2, -vv)"`
 	}
 
-	arg.MustParse(&args)
-	file, err := os.Open(args.Path)
-	if err != nil {
-		panic(err)
+	os.Args = expandVerbose(os.Args, &args)
+	p := arg.MustParse(&args)
+	if args.NDJSON {
+		args.Format = "ndjson"
+	}
+
+	if args.Out != "" {
+		if args.Output != "" && args.Output != args.Out {
+			p.Fail("--out and --output are the same option")
+		}
+
+		args.Output = args.Out
+	}
+
+	if args.Format != "text" && args.Format != "json" && args.Format != "ndjson" && args.Format != "proto" {
+		p.Fail("unknown format: " + args.Format)
+	}
+
+	if args.Base64Output != "" {
+		mode, err := parseBase64Mode(args.Base64Output)
+		if err != nil {
+			p.Fail(err.Error())
+		}
+
+		if (args.Redact || args.RedactHash) && (mode == BASE64_AUTO || mode == BASE64_ALWAYS) {
+			p.Fail("the BINLOG statements of --base64-output " + mode + " can not be redacted")
+		}
+
+		args.Base64Output = mode
 	}
 
-	defer file.Close()
+	if args.UTC {
+		SetTimeLocation(time.UTC)
+	}
+
+	types := make(map[LogEventType]bool)
+	for _, name := range args.Types {
+		t, err := ParseLogEventType(name)
+		if err != nil {
+			p.Fail(err.Error())
+		}
+
+		types[t] = true
+	}
 
-	parser, err := NewParser(file)
+	filter := newSchemaFilter(args.Databases, args.ExcludeDatabases)
+	gtids, err := newGtidFilter(args.IncludeGtids, args.ExcludeGtids)
 	if err != nil {
-		panic(err)
+		p.Fail(err.Error())
+	}
+
+	dest := os.Stdout
+	if args.Output != "" {
+		if dest, err = os.Create(args.Output); err != nil {
+			fatal(err)
+		}
+	}
+
+	output = bufio.NewWriter(dest)
+	defer func() {
+		if err := output.Flush(); err != nil {
+			fatal(err)
+		}
+
+		dest.Close()
+	}()
+
+	handleSignals()
+
+	setup := func(parser *Parser) {
+		parser.SetStrictFDE(args.StrictFDE)
+		if args.SkipCorrupt {
+			parser.SetErrorPolicy(SKIP_CORRUPT)
+			parser.SetLogger(stderrLogger{})
+		}
+	}
+
+	if info, err := os.Stat(args.Path); err == nil && info.IsDir() {
+		if !args.Summary {
+			p.Fail("a directory of binlogs is only supported by --summary")
+		}
+
+		threads := args.Threads
+		if threads <= 0 {
+			threads = runtime.NumCPU()
+		}
+
+		if err = printDirSummary(output, args.Path, threads, args.WarnEventSize, args.Relay, setup); err != nil {
+			fatal(err)
+		}
+
+		return
+	}
+
+	var file *os.File
+	var parser *Parser
+	if args.Follow {
+		file, parser, err = openFollow(args.Path, args.Relay)
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		file, err = os.Open(args.Path)
+		if err != nil {
+			panic(err)
+		}
+
+		if args.Relay {
+			parser, err = NewRelayLogParser(file)
+		} else {
+			parser, err = NewParser(file)
+		}
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	defer func() {
+		file.Close()
+	}()
+
+	var redact *RedactOptions
+	if args.Redact || args.RedactHash {
+		redact = &RedactOptions{Hash: args.RedactHash}
+	}
+
+	// configure applies the options to the parser of the binlog, and of the
+	// next binlog after a rotation with --follow
+	configure := func(parser *Parser) {
+		setup(parser)
+		if args.Progress {
+			parser.SetProgressFunc(func(pos, total int64) {
+				fmt.Fprintf(os.Stderr, "\rprogress: %5.1f%% (%d/%d)", float64(pos)*100/float64(total), pos, total)
+				if pos >= total {
+					fmt.Fprintln(os.Stderr)
+				}
+			}, 1<<20)
+		}
+
+		if redact != nil {
+			parser.SetRedactOptions(redact)
+		}
+
+		if args.CheckTimestamps {
+			parser.SetTimestampWarningFunc(func(pos int64, prev, cur uint32) {
+				fmt.Fprintf(os.Stderr, "warning: timestamp of the event at %d goes backwards from %d to %d\n", pos, prev, cur)
+			})
+		}
+	}
+
+	configure(parser)
+	if args.GtidSet {
+		// GtidSet reads the binlog at once, it is left behind on an
+		// interruption since nothing is printed yet
+		var previous, contained GTIDSet
+		done := make(chan error, 1)
+		go func() {
+			var err error
+			previous, contained, err = parser.GtidSet()
+			done <- err
+		}()
+
+		select {
+		case err = <-done:
+		case <-interrupted:
+			err = errInterrupted
+		}
+
+		if err != nil {
+			fatal(err)
+		}
+
+		fmt.Fprintf(output, "previous: %v\ncontained: %v\n", previous, contained)
+		return
+	}
+
+	if args.ExtractFrom != "" {
+		if isTerminal(dest) {
+			p.Fail("--extract-from-gtid writes a binlog, redirect it or use --out")
+		}
+
+		if err = extractFromGtid(output, parser, args.ExtractFrom); err != nil {
+			// do not leave a truncated binlog which looks valid
+			if args.Output != "" {
+				dest.Close()
+				os.Remove(args.Output)
+			}
+
+			fatal(err)
+		}
+
+		return
+	}
+
+	if args.FormatDesc {
+		if err = printFormatDescription(output, parser, args.Path); err != nil {
+			fatal(err)
+		}
+
+		return
+	}
+
+	if args.At > 0 {
+		if err = parser.SeekToPos(args.At); err != nil {
+			fatal(err)
+		}
+
+		event, err := parser.ReadEvent()
+		if err == io.EOF {
+			fatal(fmt.Errorf("No event at offset %d", args.At))
+		} else if err != nil {
+			fatal(err)
+		}
+
+		if err = printEvent(output, event, args.At, args.Format, args.Verbose); err != nil {
+			fatal(err)
+		}
+
+		return
+	}
+
+	if args.StartPos > 0 {
+		if err = parser.SeekToPos(args.StartPos); err != nil {
+			fatal(err)
+		}
 	}
 
 	for i := 0; i < args.Start; i++ {
@@ -40,16 +302,265 @@ func main() {
 		}
 	}
 
-	for i := 0; args.Count < 0 || i < args.Count; i++ {
+	if args.Tail > 0 {
+		pos, err := tailPosition(file, parser, args.Tail)
+		if err != nil {
+			fatal(err)
+		}
+
+		if err = parser.SeekToPos(pos); err != nil {
+			fatal(err)
+		}
+	}
+
+	if args.Validate {
+		if !validate(output, parser, args.WarnEventSize, args.Relay) {
+			exit(1)
+		}
+
+		return
+	}
+
+	if args.Top > 0 {
+		if err = printTopTransactions(output, parser, args.Top); err != nil {
+			fatal(err)
+		}
+
+		return
+	}
+
+	if args.SlowQueries >= 0 {
+		if err = printSlowQueries(output, parser, args.SlowQueries, redact); err != nil {
+			fatal(err)
+		}
+
+		return
+	}
+
+	if args.Unsupported {
+		if err = printUnsupported(output, parser); err != nil {
+			fatal(err)
+		}
+
+		return
+	}
+
+	if args.Summary {
+		if err = printSummary(output, parser, args.WarnEventSize); err != nil {
+			fatal(err)
+		}
+
+		return
+	}
+
+	var script *sqlScript
+	if args.Base64Output != "" {
+		script = newSQLScript(output, args.Base64Output, redact)
+	}
+
+	var paging *pager
+	if args.Interactive {
+		paging = newPager(dest, file)
+	}
+
+	schema, gtid, path, next := "", "", args.Path, ""
+	for i := 0; (args.Count < 0 || i < args.Count) && !isInterrupted(); {
+		if next != "" {
+			file.Close()
+			path, next = next, ""
+			if file, parser, err = openFollow(path, args.Relay); err != nil {
+				if isInterrupted() {
+					break
+				}
+
+				fatal(err)
+			}
+
+			configure(parser)
+		}
+
 		event, err := parser.ReadEvent()
 		if err != nil {
-			if err == io.EOF {
+			// an event cut off by the interruption of --follow is not an error
+			if err == io.EOF || isInterrupted() {
 				break
 			}
 
-			panic(err)
+			fatal(err)
+		}
+
+		pos := parser.Position() - int64(event.Header().EventSize)
+		if args.StopPos > 0 && parser.Position() > args.StopPos {
+			break
+		}
+
+		if args.Follow {
+			next = nextBinlogPath(event, path)
+		}
+
+		if paging != nil && !paging.next(event) {
+			break
+		}
+
+		switch e := event.(type) {
+		case *GtidLogEvent:
+			gtid = e.String()
+		case *MariadbGtidEvent:
+			gtid = e.String()
+		}
+
+		if !gtids.match(event) {
+			// the rest of the transaction is skipped at once, with a seek
+			// if the GTID event tells its length
+			if _, ok := event.(*GtidLogEvent); ok {
+				if err = parser.SkipTransaction(); err != nil {
+					fatal(err)
+				}
+			}
+
+			continue
+		}
+
+		if len(types) != 0 && !types[event.Header().EventType] || !filter.match(event) {
+			continue
+		}
+
+		if t := event.Header().EventType; args.SkipGtids && (t == GTID_LOG_EVENT || t == ANONYMOUS_GTID_LOG_EVENT || t == GTID_EVENT) {
+			continue
+		}
+
+		if args.ChangeRecords {
+			rows, ok := event.(*RowsEvent)
+			if !ok {
+				continue
+			}
+
+			if err = printChangeRecords(output, rows, filepath.Base(path), pos, gtid); err != nil {
+				fatal(err)
+			}
+		} else if args.DDLOnly {
+			query, ok := event.(*QueryEvent)
+			if !ok || !query.IsDDL() {
+				continue
+			}
+
+			printDDL(output, query, pos, &schema, redact)
+		} else if script != nil {
+			if err = script.print(event, pos, parser.RawEvent()); err != nil {
+				fatal(err)
+			}
+		} else if err = printEvent(output, event, pos, args.Format, args.Verbose); err != nil {
+			fatal(err)
+		}
+
+		if args.Follow || args.Format == "ndjson" {
+			output.Flush()
+		}
+
+		if paging != nil {
+			paging.printed = true
+		}
+
+		i++
+	}
+
+	if script != nil {
+		script.close()
+	}
+}
+
+func printEvent(w io.Writer, event BinLogEvent, pos int64, format string, verbose int) error {
+	var text []byte
+	var err error
+	switch format {
+	case "json":
+		if text, err = MarshalEventJSON(event); err == nil {
+			text = append(text, '\n')
+		}
+	case "ndjson":
+		if text, err = MarshalEventNDJSON(event, pos); err == nil {
+			text = append(text, '\n')
+		}
+	case "proto":
+		text, err = MarshalEventProtoDelimited(event)
+	default:
+		PrintEventVerbose(w, event, verbose)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(text)
+	return err
+}
+
+// expandVerbose replaces -v and -vv, which go-arg can not count, with
+// --verbose=1 and --verbose=2. The value of an option of dest, e.g. -o -v,
+// and the arguments after -- are kept.
+func expandVerbose(args []string, dest interface{}) []string {
+	valued := valueOptions(dest)
+	expanded := make([]string, len(args))
+	copy(expanded, args)
+	for i := 1; i < len(expanded); i++ {
+		arg := expanded[i]
+		if arg == "--" {
+			break
+		}
+
+		if valued[arg] {
+			i++
+		} else if len(arg) > 1 && strings.Trim(arg, "v") == "-" {
+			expanded[i] = fmt.Sprintf("--verbose=%d", len(arg)-1)
+		}
+	}
+
+	return expanded
+}
+
+// valueOptions returns the names of the options of the go-arg struct dest
+// which take a value, i.e. which are not bool. The long name is the
+// lowercase field name unless the arg tag gives one.
+func valueOptions(dest interface{}) map[string]bool {
+	names := make(map[string]bool)
+	t := reflect.TypeOf(dest).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type.Kind() == reflect.Bool {
+			continue
 		}
 
-		PrintEvent(os.Stdout, event)
+		long := "--" + strings.ToLower(field.Name)
+		for _, name := range strings.Split(field.Tag.Get("arg"), ",") {
+			if strings.HasPrefix(name, "--") {
+				long = name
+			} else if strings.HasPrefix(name, "-") {
+				names[name] = true
+			}
+		}
+
+		names[long] = true
+	}
+
+	return names
+}
+
+// exit flushes the output first, which the deferred calls of main do not
+// since os.Exit does not run them
+func exit(code int) {
+	if output != nil {
+		output.Flush()
+	}
+
+	os.Exit(code)
+}
+
+func fatal(err error) {
+	if output != nil {
+		output.Flush()
 	}
+
+	fmt.Fprintf(os.Stderr, "%v\n", err)
+	os.Exit(1)
 }
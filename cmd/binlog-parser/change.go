@@ -0,0 +1,32 @@
+//
+// change.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package main
+
+import (
+	"fmt"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"io"
+)
+
+// printChangeRecords prints the change records of a rows event, one JSON
+// object per line
+func printChangeRecords(w io.Writer, event *RowsEvent, file string, pos int64, gtid string) error {
+	records, err := NewChangeRecords(event, file, pos, gtid)
+	if err != nil {
+		return fmt.Errorf("offset %d: %v", pos, err)
+	}
+
+	for _, record := range records {
+		text, err := MarshalChangeRecord(record)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%s\n", text)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+//
+// ddl.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package main
+
+import (
+	"fmt"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"io"
+	"strings"
+	"time"
+)
+
+// printDDL prints the statement of a DDL query event as SQL, preceded by the
+// timestamp and the offset of the event, and a USE statement if the default
+// database changes. The string literals are redacted unless redact is nil.
+func printDDL(w io.Writer, event *QueryEvent, pos int64, schema *string, redact *RedactOptions) {
+	ts := event.Header().Time(time.UTC)
+	fmt.Fprintf(w, "-- %s at %d\n", ts.Format("2006-01-02 15:04:05 UTC"), pos)
+	if event.Schema() != "" && event.Schema() != *schema {
+		*schema = event.Schema()
+		fmt.Fprintf(w, "%s;\n", event.UseStatement())
+	}
+
+	query := event.Query()
+	if redact != nil {
+		query = RedactQuery(query, redact)
+	}
+
+	fmt.Fprintf(w, "%s;\n", strings.TrimRight(strings.TrimSpace(query), ";"))
+}
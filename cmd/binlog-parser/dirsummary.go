@@ -0,0 +1,139 @@
+//
+// dirsummary.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Profile all the binlogs of a directory in parallel
+//
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileSummary is the summary of one binlog of a directory, warnings are the
+// messages of the events larger than the max size
+type fileSummary struct {
+	name     string
+	stat     *summary
+	warnings bytes.Buffer
+	err      error
+}
+
+// printDirSummary summarizes the binlogs of a directory, i.e. the files
+// starting with the magic number, with up to threads of them parsed at the
+// same time, and prints a line per binlog and the summary of all of them.
+// setup is called with the parser of each binlog. An error is returned after
+// the summary if any binlog fails to parse.
+func printDirSummary(w io.Writer, dir string, threads int, maxSize uint32, relay bool, setup func(*Parser)) error {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var files []*fileSummary
+	for _, info := range infos {
+		path := filepath.Join(dir, info.Name())
+		if info.Mode().IsRegular() && isBinlog(path) {
+			files = append(files, &fileSummary{name: info.Name()})
+		}
+	}
+
+	if len(files) == 0 {
+		return fmt.Errorf("No binlog in %s", dir)
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, threads)
+	for _, file := range files {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(file *fileSummary) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			file.stat, file.err = summarizeFile(&file.warnings, filepath.Join(dir, file.name), maxSize, relay, setup)
+		}(file)
+	}
+
+	wg.Wait()
+	if isInterrupted() {
+		return errInterrupted
+	}
+
+	total := &summary{counts: make(map[LogEventType]int)}
+	failed := 0
+	fmt.Fprintf(w, "%-30s %-10s %-12s %-14s %s\n", "FILE", "EVENTS", "TRANSACTIONS", "BYTES", "FORMAT")
+	for _, file := range files {
+		if file.err != nil {
+			fmt.Fprintf(w, "%-30s ERROR: %v\n", file.name, file.err)
+			failed++
+			continue
+		}
+
+		fmt.Fprintf(w, "%-30s %-10d %-12d %-14d %v\n", file.name, file.stat.events, file.stat.txns,
+			file.stat.size, file.stat.format)
+		total.merge(file.stat)
+	}
+
+	for _, file := range files {
+		if file.warnings.Len() != 0 {
+			fmt.Fprintf(w, "%s:\n", file.name)
+			file.warnings.WriteTo(w)
+		}
+	}
+
+	fmt.Fprintf(w, "binlogs: %d\n", len(files)-failed)
+	total.print(w)
+	if failed != 0 {
+		return fmt.Errorf("%d of %d binlogs failed to parse", failed, len(files))
+	}
+
+	return nil
+}
+
+// isBinlog reports whether the file starts with the magic number of binlog
+func isBinlog(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+
+	defer file.Close()
+	magic := make([]byte, 4)
+	_, err = io.ReadFull(file, magic)
+	return err == nil && bytes.Equal(magic, []byte{0xfe, 'b', 'i', 'n'})
+}
+
+func summarizeFile(w io.Writer, path string, maxSize uint32, relay bool, setup func(*Parser)) (*summary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+	var parser *Parser
+	if relay {
+		parser, err = NewRelayLogParser(file)
+	} else {
+		parser, err = NewParser(file)
+	}
+
+	if err == ErrNoEvents {
+		return &summary{counts: make(map[LogEventType]int)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	setup(parser)
+	return summarize(w, parser, maxSize)
+}
@@ -0,0 +1,91 @@
+//
+// extract.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Write a binlog of the transactions from a GTID on, e.g. to replay them with
+// mysqlbinlog | mysql for a point-in-time recovery
+//
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"io"
+)
+
+// extractFromGtid writes a binlog of the FDE of the binlog and the events
+// from the GTID event of gtid, e.g. 3e11fa47-71ca-11e1-9e33-c80aa9429562:23,
+// to the end. PREVIOUS_GTIDS_LOG_EVENT is left out, since the GTIDs before
+// the transaction are not executed by the replay. Nothing is written if the
+// GTID is not in the binlog.
+func extractFromGtid(w io.Writer, parser *Parser, gtid string) error {
+	set, err := ParseGtidSet(gtid)
+	if err != nil {
+		return err
+	}
+
+	for _, intervals := range set {
+		if len(set) != 1 || len(intervals) != 1 || intervals[0].To-intervals[0].From != 1 {
+			return fmt.Errorf("Invalid GTID %q, it should be uuid:N", gtid)
+		}
+	}
+
+	// the FDE is written with the first event of the transaction, so that
+	// nothing is written if the GTID is not found
+	var writer *Writer
+	var fde []byte
+	found := false
+	for {
+		if isInterrupted() {
+			return errInterrupted
+		}
+
+		event, err := parser.ReadEvent()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		switch e := event.(type) {
+		case *FormatDescriptionEvent:
+			if fde == nil {
+				fde = parser.RawEvent()
+			}
+
+			continue
+		case *PreviousGtidsLogEvent:
+			continue
+		case *GtidLogEvent:
+			if !found && fde != nil && event.Header().EventType == GTID_LOG_EVENT && set.Contains(e.Sid, uint64(e.Gno)) {
+				if writer, err = NewWriter(w); err != nil {
+					return err
+				}
+
+				if err = writer.WriteEvent(fde); err != nil {
+					return err
+				}
+
+				found = true
+			}
+		}
+
+		if found {
+			if err = writer.WriteEvent(parser.RawEvent()); err != nil {
+				return err
+			}
+		}
+	}
+
+	if fde == nil {
+		return errors.New("The binlog has no FormatDescriptionEvent")
+	}
+
+	if !found {
+		return fmt.Errorf("GTID %s is not in the binlog", gtid)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+//
+// fde.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Print the format of a binlog as a JSON line
+//
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"io"
+)
+
+type formatDescription struct {
+	File string `json:"file"`
+	*FormatDescriptionSummary
+}
+
+// printFormatDescription prints the summary of the FDE at the start of the
+// binlog, or of START_EVENT_V3 of the binlogs older than MySQL 5.0
+func printFormatDescription(w io.Writer, parser *Parser, file string) error {
+	event, err := parser.ReadEvent()
+	if err != nil {
+		return err
+	}
+
+	desc := formatDescription{File: file}
+	switch e := event.(type) {
+	case *FormatDescriptionEvent:
+		desc.FormatDescriptionSummary = e.Summary()
+	case *StartEventV3:
+		desc.FormatDescriptionSummary = &FormatDescriptionSummary{
+			BinlogVersion:   e.BinlogVersion,
+			ServerVersion:   e.MySQLServerVersion,
+			ChecksumAlg:     BINLOG_CHECKSUM_ALG_OFF.String(),
+			CreateTimestamp: e.CreateTimestamp,
+		}
+	default:
+		return fmt.Errorf("The first event is %v instead of FORMAT_DESCRIPTION_EVENT", event.Header().EventType)
+	}
+
+	text, err := json.Marshal(desc)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", text)
+	return err
+}
@@ -0,0 +1,141 @@
+//
+// filter.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Filter the events by database and GTID
+//
+
+package main
+
+import (
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"strings"
+)
+
+type schemaFilter struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// newSchemaFilter returns nil if no database is given, each name may be a
+// comma separated list
+func newSchemaFilter(include, exclude []string) *schemaFilter {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil
+	}
+
+	filter := &schemaFilter{splitNames(include), splitNames(exclude)}
+	if len(filter.include) == 0 {
+		filter.include = nil
+	}
+
+	return filter
+}
+
+func splitNames(names []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, name := range names {
+		for _, s := range strings.Split(name, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				set[s] = true
+			}
+		}
+	}
+
+	return set
+}
+
+// eventSchema returns the database of the event, and false for the events
+// which do not belong to a database, e.g. XID, GTID or BEGIN/COMMIT, so
+// that the transaction structure is kept
+func eventSchema(event BinLogEvent) (string, bool) {
+	switch e := event.(type) {
+	case *QueryEvent:
+		query := strings.ToUpper(strings.TrimSpace(e.Query()))
+		for _, stmt := range []string{"BEGIN", "COMMIT", "ROLLBACK", "XA ", "SAVEPOINT", "RELEASE SAVEPOINT"} {
+			if strings.HasPrefix(query, stmt) {
+				return "", false
+			}
+		}
+
+		return e.Schema(), true
+	case *TableMapEvent:
+		return e.Schema, true
+	case *RowsEvent:
+		if e.TableMap() != nil {
+			return e.TableMap().Schema, true
+		}
+	}
+
+	return "", false
+}
+
+func (self *schemaFilter) match(event BinLogEvent) bool {
+	schema, ok := eventSchema(event)
+	if self == nil || !ok {
+		return true
+	}
+
+	if self.include != nil && !self.include[schema] {
+		return false
+	}
+
+	return !self.exclude[schema]
+}
+
+// gtidFilter keeps the transactions whose GTID is in include, if not nil,
+// and not in exclude. The events which do not belong to any transaction, e.g.
+// FORMAT_DESCRIPTION_EVENT, are kept, while a transaction without GTID is
+// kept only if include is nil.
+type gtidFilter struct {
+	include GTIDSet
+	exclude GTIDSet
+	tracker TransactionTracker
+	keep    bool // the current transaction is kept
+}
+
+// newGtidFilter returns nil if neither set is given
+func newGtidFilter(include, exclude string) (*gtidFilter, error) {
+	if include == "" && exclude == "" {
+		return nil, nil
+	}
+
+	filter := new(gtidFilter)
+	var err error
+	if include != "" {
+		if filter.include, err = ParseGtidSet(include); err != nil {
+			return nil, err
+		}
+	}
+
+	if filter.exclude, err = ParseGtidSet(exclude); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// match must be called with every event in order to follow the transactions
+func (self *gtidFilter) match(event BinLogEvent) bool {
+	if self == nil {
+		return true
+	}
+
+	state, _ := self.tracker.Track(event)
+	switch state {
+	case TXN_NONE:
+		return true
+	case TXN_BEGIN:
+		gtid, ok := event.(*GtidLogEvent)
+		if !ok || event.Header().EventType != GTID_LOG_EVENT {
+			self.keep = self.include == nil
+			break
+		}
+
+		gno := uint64(gtid.Gno)
+		self.keep = (self.include == nil || self.include.Contains(gtid.Sid, gno)) &&
+			!self.exclude.Contains(gtid.Sid, gno)
+	}
+
+	return self.keep
+}
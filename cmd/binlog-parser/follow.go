@@ -0,0 +1,94 @@
+//
+// follow.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+
+package main
+
+import (
+	"fmt"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const FOLLOW_INTERVAL = 200 * time.Millisecond
+
+// followReader reads a binlog which is being written, it waits for more data
+// at the end of the file instead of returning io.EOF, unless interrupted
+type followReader struct {
+	*os.File
+}
+
+func (self followReader) Read(buf []byte) (int, error) {
+	for {
+		n, err := self.File.Read(buf)
+		if n > 0 || err != io.EOF || isInterrupted() {
+			return n, err
+		}
+
+		time.Sleep(FOLLOW_INTERVAL)
+	}
+}
+
+// openFollow opens a binlog to follow, waiting for it to be created
+func openFollow(path string, relay bool) (*os.File, *Parser, error) {
+	for {
+		file, err := os.Open(path)
+		if err == nil {
+			var parser *Parser
+			if relay {
+				parser, err = NewRelayLogParser(followReader{file})
+			} else {
+				parser, err = NewParser(followReader{file})
+			}
+
+			if err != nil {
+				file.Close()
+				return nil, nil, err
+			}
+
+			return file, parser, nil
+		}
+
+		if !os.IsNotExist(err) || isInterrupted() {
+			return nil, nil, err
+		}
+
+		time.Sleep(FOLLOW_INTERVAL)
+	}
+}
+
+// nextBinlogPath returns the path of the binlog following the current one if
+// the event is the last one of the current binlog, i.e. a ROTATE which is not
+// artificial, or a STOP after which the server starts a new binlog with the
+// next sequence number. It returns an empty string otherwise.
+func nextBinlogPath(event BinLogEvent, path string) string {
+	switch e := event.(type) {
+	case *RotateEvent:
+		if e.Header().Flags&LOG_EVENT_ARTIFICIAL_F != 0 {
+			return ""
+		}
+
+		return filepath.Join(filepath.Dir(path), e.NextBinlog())
+	}
+
+	if event.Header().EventType != STOP_EVENT {
+		return ""
+	}
+
+	ext := filepath.Ext(path)
+	if len(ext) < 2 {
+		return ""
+	}
+
+	seq, err := strconv.ParseUint(ext[1:], 10, 64)
+	if err != nil {
+		return ""
+	}
+
+	return path[:len(path)-len(ext)] + fmt.Sprintf(".%0*d", len(ext)-1, seq+1)
+}
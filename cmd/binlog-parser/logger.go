@@ -0,0 +1,21 @@
+//
+// logger.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Print the warnings of the parser on stderr
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type stderrLogger struct{}
+
+func (stderrLogger) Debugf(format string, args ...interface{}) {}
+
+func (stderrLogger) Warnf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "warning: "+format+"\n", args...)
+}
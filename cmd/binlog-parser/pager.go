@@ -0,0 +1,84 @@
+//
+// pager.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Print the events a transaction at a time and wait for Enter like less
+//
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"os"
+	"strings"
+)
+
+// pager pauses after each transaction, or each event which is not part of a
+// transaction, if anything of it is printed
+type pager struct {
+	lines    chan string
+	tracker  TransactionTracker
+	boundary bool // the last event ends a transaction or is not part of one
+	printed  bool // anything is printed since the last pause
+}
+
+// newPager returns nil if the output is not a terminal, or stdin is the
+// binlog itself, then the events are printed without pauses
+func newPager(dest, binlog *os.File) *pager {
+	if !isTerminal(dest) {
+		return nil
+	}
+
+	stdin, err := os.Stdin.Stat()
+	if err != nil {
+		return nil
+	}
+
+	if info, err := binlog.Stat(); err != nil || os.SameFile(stdin, info) {
+		return nil
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+
+		close(lines)
+	}()
+
+	return &pager{lines: lines}
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// next is called with each event read before it is printed, it waits for
+// Enter if the previous event ends a page and returns false on q, at the end
+// of stdin or on SIGINT
+func (self *pager) next(event BinLogEvent) bool {
+	if self.boundary && self.printed {
+		output.Flush()
+		fmt.Fprint(os.Stderr, "-- Enter for the next transaction, q to quit -- ")
+		select {
+		case line, ok := <-self.lines:
+			if !ok || strings.TrimSpace(line) == "q" {
+				return false
+			}
+		case <-interrupted:
+			fmt.Fprintln(os.Stderr)
+			return false
+		}
+
+		self.printed = false
+	}
+
+	state, end := self.tracker.Track(event)
+	self.boundary = state == TXN_NONE || end
+	return true
+}
@@ -0,0 +1,42 @@
+//
+// signal.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Stop printing the events cleanly on SIGINT or SIGTERM
+//
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// closed on the first SIGINT or SIGTERM, the read loop stops after the
+// current event and the output is flushed, a second signal kills at once
+var interrupted = make(chan struct{})
+
+// errInterrupted stops the modes which report after reading the whole binlog,
+// e.g. --summary, since what they would print is incomplete
+var errInterrupted = errors.New("Interrupted")
+
+func handleSignals() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ch
+		signal.Stop(ch)
+		close(interrupted)
+	}()
+}
+
+func isInterrupted() bool {
+	select {
+	case <-interrupted:
+		return true
+	default:
+		return false
+	}
+}
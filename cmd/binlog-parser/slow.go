@@ -0,0 +1,85 @@
+//
+// slow.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Report the queries which took long on the master
+//
+
+package main
+
+import (
+	"fmt"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type slowQuery struct {
+	gtid    string
+	start   uint32
+	pos     int64
+	seconds uint32
+	query   string
+}
+
+// printSlowQueries prints the query events whose execution time exceeds
+// seconds, the slowest first. The string literals are redacted unless redact
+// is nil.
+func printSlowQueries(w io.Writer, parser *Parser, seconds int, redact *RedactOptions) error {
+	var queries []*slowQuery
+	gtid := "-"
+	for {
+		if isInterrupted() {
+			return errInterrupted
+		}
+
+		pos := parser.Position()
+		event, err := parser.ReadEvent()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return err
+		}
+
+		switch e := event.(type) {
+		case *GtidLogEvent:
+			gtid = e.String()
+		case *MariadbGtidEvent:
+			gtid = e.String()
+		case *QueryEvent:
+			if int64(e.ExecutionTime()) <= int64(seconds) {
+				continue
+			}
+
+			query := e.Query()
+			if redact != nil {
+				query = RedactQuery(query, redact)
+			}
+
+			queries = append(queries, &slowQuery{
+				gtid:    gtid,
+				start:   e.Header().Timestamp,
+				pos:     pos,
+				seconds: e.ExecutionTime(),
+				query:   strings.Join(strings.Fields(query), " "),
+			})
+		}
+	}
+
+	sort.SliceStable(queries, func(i, j int) bool {
+		return queries[i].seconds > queries[j].seconds
+	})
+
+	fmt.Fprintf(w, "%-4s %-8s %-25s %-10s %-48s %s\n", "#", "SECONDS", "START", "POS", "GTID", "QUERY")
+	for i, query := range queries {
+		fmt.Fprintf(w, "%-4d %-8d %-25s %-10d %-48s %s\n", i+1, query.seconds,
+			time.Unix(int64(query.start), 0).In(TimeLocation()).Format("2006-01-02 15:04:05"),
+			query.pos, query.gtid, query.query)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+//
+// summary.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Profile the events of a binlog
+//
+
+package main
+
+import (
+	"fmt"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"io"
+	"sort"
+)
+
+type dmlStat struct {
+	events int
+	rows   int
+}
+
+func (self *dmlStat) add(other dmlStat) {
+	self.events += other.events
+	self.rows += other.rows
+}
+
+type summary struct {
+	events, txns, txnEvents, ddls, admins int
+	inserts, updates, deletes             dmlStat
+	format                                BinlogFormat
+	counts                                map[LogEventType]int
+	size                                  int64 // bytes read
+}
+
+func printSummary(w io.Writer, parser *Parser, maxSize uint32) error {
+	stat, err := summarize(w, parser, maxSize)
+	if err != nil {
+		return err
+	}
+
+	stat.print(w)
+	return nil
+}
+
+// summarize reads the rest of the binlog and counts its events, the events
+// larger than maxSize are reported to w unless maxSize is 0
+func summarize(w io.Writer, parser *Parser, maxSize uint32) (*summary, error) {
+	var tracker TransactionTracker
+	stat := &summary{counts: make(map[LogEventType]int)}
+	start := parser.Position()
+	for {
+		if isInterrupted() {
+			return nil, errInterrupted
+		}
+
+		pos := parser.Position()
+		event, err := parser.ReadEvent()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return nil, err
+		}
+
+		checkEventSize(w, event, pos, maxSize)
+		stat.format.Add(event)
+		t := event.Header().EventType
+		stat.events++
+		stat.counts[t]++
+		switch state, _ := tracker.Track(event); state {
+		case TXN_NONE:
+			stat.admins++
+		case TXN_BEGIN:
+			stat.txns++
+			fallthrough
+		default:
+			stat.txnEvents++
+		}
+
+		switch e := event.(type) {
+		case *RowsEvent:
+			dml := &stat.inserts
+			rows := len(e.Rows())
+			switch t {
+			case UPDATE_ROWS_EVENT_V1, UPDATE_ROWS_EVENT, PARTIAL_UPDATE_ROWS_EVENT:
+				dml = &stat.updates
+				rows /= 2
+			case DELETE_ROWS_EVENT_V1, DELETE_ROWS_EVENT:
+				dml = &stat.deletes
+			}
+
+			dml.events++
+			dml.rows += rows
+		case *QueryEvent:
+			if e.IsDDL() {
+				stat.ddls++
+			}
+		}
+	}
+
+	stat.size = parser.Position() - start
+	return stat, nil
+}
+
+// merge adds the counts of other, e.g. of another binlog
+func (self *summary) merge(other *summary) {
+	self.events += other.events
+	self.txns += other.txns
+	self.txnEvents += other.txnEvents
+	self.ddls += other.ddls
+	self.admins += other.admins
+	self.inserts.add(other.inserts)
+	self.updates.add(other.updates)
+	self.deletes.add(other.deletes)
+
+	self.format |= other.format
+	for t, n := range other.counts {
+		self.counts[t] += n
+	}
+
+	self.size += other.size
+}
+
+func (self *summary) print(w io.Writer) {
+	avg := 0.0
+	if self.txns != 0 {
+		avg = float64(self.txnEvents) / float64(self.txns)
+	}
+
+	fmt.Fprintf(w, "events: %d\n", self.events)
+	fmt.Fprintf(w, "transactions: %d\n", self.txns)
+	fmt.Fprintf(w, "events per transaction: %.2f\n", avg)
+	fmt.Fprintf(w, "insert row events: %d (%d rows)\n", self.inserts.events, self.inserts.rows)
+	fmt.Fprintf(w, "update row events: %d (%d rows)\n", self.updates.events, self.updates.rows)
+	fmt.Fprintf(w, "delete row events: %d (%d rows)\n", self.deletes.events, self.deletes.rows)
+	fmt.Fprintf(w, "ddl statements: %d\n", self.ddls)
+	fmt.Fprintf(w, "administrative events: %d\n", self.admins)
+	fmt.Fprintf(w, "binlog format: %v\n", self.format)
+
+	types := make([]LogEventType, 0, len(self.counts))
+	for t := range self.counts {
+		types = append(types, t)
+	}
+
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	fmt.Fprintln(w, "events by type:")
+	for _, t := range types {
+		fmt.Fprintf(w, "\t%-30s %d\n", t, self.counts[t])
+	}
+}
@@ -0,0 +1,51 @@
+//
+// tail.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Find the last events of a binlog
+//
+
+package main
+
+import (
+	"errors"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"io"
+	"os"
+)
+
+// tailPosition scans the rest of the binlog by the event headers, keeping the
+// offsets of the last n events in a ring, and returns the offset of the n-th
+// last event. The events are skipped by seeking, so it does not work on a
+// pipe, which can not be read again from that offset anyway.
+func tailPosition(file *os.File, parser *Parser, n int) (int64, error) {
+	if _, err := file.Seek(0, io.SeekCurrent); err != nil {
+		return 0, errors.New("--tail requires a seekable binlog")
+	}
+
+	ring := make([]int64, n)
+	count := 0
+	for {
+		pos := parser.Position()
+		if err := parser.SkipEvent(); err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return 0, err
+		}
+
+		ring[count%n] = pos
+		count++
+	}
+
+	if count == 0 {
+		return parser.Position(), nil
+	}
+
+	if count < n {
+		return ring[0], nil
+	}
+
+	return ring[count%n], nil
+}
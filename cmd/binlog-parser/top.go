@@ -0,0 +1,127 @@
+//
+// top.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Report the largest transactions of a binlog
+//
+
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"io"
+	"sort"
+	"time"
+)
+
+type txnStat struct {
+	gtid   string
+	xid    string
+	start  uint32
+	pos    int64
+	events int
+	size   uint64
+}
+
+func (self *txnStat) less(other *txnStat) bool {
+	if self.size != other.size {
+		return self.size < other.size
+	}
+
+	return self.events < other.events
+}
+
+// min-heap, the smallest of the kept transactions is at the top
+type txnHeap []*txnStat
+
+func (h txnHeap) Len() int            { return len(h) }
+func (h txnHeap) Less(i, j int) bool  { return h[i].less(h[j]) }
+func (h txnHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *txnHeap) Push(x interface{}) { *h = append(*h, x.(*txnStat)) }
+func (h *txnHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// push keeps the n largest transactions
+func (h *txnHeap) push(stat *txnStat, n int) {
+	if h.Len() < n {
+		heap.Push(h, stat)
+	} else if (*h)[0].less(stat) {
+		(*h)[0] = stat
+		heap.Fix(h, 0)
+	}
+}
+
+// printTopTransactions accumulates the size of each transaction while reading
+// the events, so that only the n largest are kept in memory
+func printTopTransactions(w io.Writer, parser *Parser, n int) error {
+	h := make(txnHeap, 0, n+1)
+	var tracker TransactionTracker
+	var stat *txnStat
+	for {
+		if isInterrupted() {
+			return errInterrupted
+		}
+
+		pos := parser.Position()
+		event, err := parser.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		state, end := tracker.Track(event)
+		if state == TXN_NONE {
+			continue
+		}
+
+		header := event.Header()
+		if state == TXN_BEGIN {
+			if stat != nil {
+				h.push(stat, n)
+			}
+
+			stat = &txnStat{gtid: "-", xid: "-", start: header.Timestamp, pos: pos}
+			switch gtid := event.(type) {
+			case *GtidLogEvent:
+				stat.gtid = gtid.String()
+			case *MariadbGtidEvent:
+				stat.gtid = gtid.String()
+			}
+		}
+
+		if xid, ok := event.(*XidEvent); ok {
+			stat.xid = fmt.Sprint(xid.Xid())
+		}
+
+		stat.events++
+		stat.size += uint64(header.EventSize)
+		if end {
+			h.push(stat, n)
+			stat = nil
+		}
+	}
+
+	// an unterminated transaction at the end of the binlog
+	if stat != nil {
+		h.push(stat, n)
+	}
+
+	sort.Sort(sort.Reverse(h))
+	fmt.Fprintf(w, "%-4s %-48s %-25s %-10s %-8s %-12s %s\n", "#", "GTID", "START", "POS", "EVENTS", "BYTES", "XID")
+	for i, stat := range h {
+		fmt.Fprintf(w, "%-4d %-48s %-25s %-10d %-8d %-12d %s\n", i+1, stat.gtid,
+			time.Unix(int64(stat.start), 0).In(TimeLocation()).Format("2006-01-02 15:04:05"),
+			stat.pos, stat.events, stat.size, stat.xid)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+//
+// unsupported.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// List the event types which are not decoded
+//
+
+package main
+
+import (
+	"fmt"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"io"
+	"sort"
+)
+
+// printUnsupported reads the rest of the binlog and prints the types of the
+// events which are decoded as UnknownBinLogEvent with their counts, and how
+// many of them are ignorable
+func printUnsupported(w io.Writer, parser *Parser) error {
+	counts := make(map[LogEventType]int)
+	ignorable := make(map[LogEventType]int)
+	for {
+		if isInterrupted() {
+			return errInterrupted
+		}
+
+		event, err := parser.ReadEvent()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return err
+		}
+
+		if unknown, ok := event.(*UnknownBinLogEvent); ok {
+			counts[event.Header().EventType]++
+			if unknown.Ignorable() {
+				ignorable[event.Header().EventType]++
+			}
+		}
+	}
+
+	if len(counts) == 0 {
+		fmt.Fprintln(w, "all the events are supported")
+		return nil
+	}
+
+	types := make([]LogEventType, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	for _, t := range types {
+		if n := ignorable[t]; n != 0 {
+			fmt.Fprintf(w, "%-30s %d (%d ignorable)\n", t, counts[t], n)
+		} else {
+			fmt.Fprintf(w, "%-30s %d\n", t, counts[t])
+		}
+	}
+
+	return nil
+}
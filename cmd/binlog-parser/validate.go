@@ -0,0 +1,112 @@
+//
+// validate.go
+// Copyright (C) 2019 Jianlong Chen <jianlong99@gmail.com>
+//
+// Check that every event of a binlog can be parsed
+//
+
+package main
+
+import (
+	"fmt"
+	. "github.com/chenjianlong/mysql-toolset/binlog"
+	"io"
+)
+
+// validate parses the rest of the binlog, it returns false on the first
+// event which fails to parse, or after all the events if the binlog has
+// INCIDENT_EVENT, which means the replicas miss the changes. The events
+// larger than maxSize are reported unless maxSize is 0. It also warns about a
+// log_pos which is not the end of its event unless in a relay log, and about
+// a binlog which ends without STOP_EVENT or ROTATE_EVENT or whose FDE still
+// has LOG_EVENT_BINLOG_IN_USE_F, i.e. the server crashed or is writing it.
+func validate(w io.Writer, parser *Parser, maxSize uint32, relay bool) bool {
+	var tracker TransactionTracker
+	var fde, last *BinLogEventHeader
+	events, txns, incidents := 0, 0, 0
+	for {
+		pos := parser.Position()
+		if isInterrupted() {
+			fmt.Fprintf(w, "ERROR: offset %d: %v\n", pos, errInterrupted)
+			return false
+		}
+
+		event, err := parser.ReadEvent()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			fmt.Fprintf(w, "ERROR: offset %d: %v\n", pos, err)
+			return false
+		}
+
+		header := event.Header()
+		checkEventSize(w, event, pos, maxSize)
+		if !relay && header.LogPos != 0 && int64(header.LogPos) != parser.Position() {
+			fmt.Fprintf(w, "WARNING: offset %d: log_pos %d of %v is not the end of the event %d\n",
+				pos, header.LogPos, header.EventType, parser.Position())
+		}
+
+		switch e := event.(type) {
+		case *IncidentEvent:
+			fmt.Fprintf(w, "ERROR: offset %d: incident %s: %s\n", pos, e.Name(), e.Message)
+			incidents++
+		case *FormatDescriptionEvent:
+			if fde == nil {
+				fde = header
+			}
+		}
+
+		last = header
+		events++
+		if state, _ := tracker.Track(event); state == TXN_BEGIN {
+			txns++
+		}
+	}
+
+	checkClosed(w, fde, last)
+	if incidents > 0 {
+		fmt.Fprintf(w, "FAILED: %d events, %d transactions, %d incidents\n", events, txns, incidents)
+		return false
+	}
+
+	fmt.Fprintf(w, "OK: %d events, %d transactions\n", events, txns)
+	return true
+}
+
+// checkClosed warns if the binlog was not closed by the server, which writes
+// STOP_EVENT on shutdown or ROTATE_EVENT on rotation and then clears
+// LOG_EVENT_BINLOG_IN_USE_F of the FDE. fde is nil if the FDE is not read,
+// e.g. with --start-position.
+func checkClosed(w io.Writer, fde, last *BinLogEventHeader) {
+	if last == nil {
+		return
+	}
+
+	closed := last.EventType == STOP_EVENT ||
+		last.EventType == ROTATE_EVENT && last.Flags&LOG_EVENT_ARTIFICIAL_F == 0
+	inUse := fde != nil && fde.Flags&LOG_EVENT_BINLOG_IN_USE_F != 0
+	if !closed {
+		reason := "the server crashed or is still writing it"
+		if inUse {
+			reason += ", LOG_EVENT_BINLOG_IN_USE_F is set"
+		}
+
+		fmt.Fprintf(w, "WARNING: the binlog ends with %v instead of STOP_EVENT or ROTATE_EVENT, %s\n",
+			last.EventType, reason)
+	} else if inUse {
+		fmt.Fprintf(w, "WARNING: LOG_EVENT_BINLOG_IN_USE_F of the format description event is set though the binlog ends with %v\n",
+			last.EventType)
+	}
+}
+
+// checkEventSize warns about an event larger than maxSize, which would break
+// the replication of a replica whose max_allowed_packet is not larger
+func checkEventSize(w io.Writer, event BinLogEvent, pos int64, maxSize uint32) {
+	header := event.Header()
+	if maxSize != 0 && header.EventSize > maxSize {
+		fmt.Fprintf(w, "WARNING: offset %d: %v of %d bytes exceeds %d bytes\n",
+			pos, header.EventType, header.EventSize, maxSize)
+	}
+}
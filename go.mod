@@ -4,4 +4,5 @@ require (
 	github.com/alexflint/go-arg v1.2.0
 	github.com/google/uuid v1.1.1
 	github.com/hashicorp/go-version v1.2.0
+	golang.org/x/text v0.13.0
 )